@@ -0,0 +1,39 @@
+// Copyright 2022 The policy-lru Authors. All rights reserved.
+//
+// Use of this source code is governed by the Apache License, Version
+// 2.0 (the "License"); you may not use this file except in compliance
+// with the License. You may find a copy of the license in the file
+// LICENSE or at  http://www.apache.org/licenses/LICENSE-2.0.
+
+//go:build go1.23
+
+package policylru
+
+import "iter"
+
+// All returns an iterator over the items in the cache in recency
+// order, starting with the most recently used item, for use with a
+// range-over-func loop:
+//
+//	for k, v := range lru.All() {
+//		...
+//	}
+//
+// Items are not promoted. Like the function passed to Range, the loop
+// body may remove the item it is visiting but must not otherwise modify
+// the cache.
+func (c *Cache[Key, Value]) All() iter.Seq2[Key, Value] {
+	return func(yield func(Key, Value) bool) {
+		if c.cache == nil {
+			return
+		}
+		for ele := c.ll.Front(); ele != nil; {
+			next := ele.Next()
+			e := ele.Value.(*entry[Key, Value])
+			if !yield(e.key, c.copyOut(e.value)) {
+				return
+			}
+			ele = next
+		}
+	}
+}
@@ -0,0 +1,91 @@
+// Copyright 2022 The policy-lru Authors. All rights reserved.
+//
+// Use of this source code is governed by the Apache License, Version
+// 2.0 (the "License"); you may not use this file except in compliance
+// with the License. You may find a copy of the license in the file
+// LICENSE or at  http://www.apache.org/licenses/LICENSE-2.0.
+
+//go:build go1.23
+
+package policylru
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAll(t *testing.T) {
+	t.Run("zero_value", func(t *testing.T) {
+		var lru Cache[string, int]
+		var calls int
+
+		lru.All()(func(string, int) bool {
+			calls++
+			return true
+		})
+
+		assert.Equal(t, 0, calls)
+	})
+	t.Run("newest_first", func(t *testing.T) {
+		lru := New[string, int](nil)
+		lru.Add("a", 1)
+		lru.Add("b", 2)
+		lru.Add("c", 3)
+		lru.Get("a")
+		var keys []string
+		var values []int
+
+		lru.All()(func(k string, v int) bool {
+			keys = append(keys, k)
+			values = append(values, v)
+			return true
+		})
+
+		assert.Equal(t, []string{"a", "c", "b"}, keys)
+		assert.Equal(t, []int{1, 3, 2}, values)
+		assert.Equal(t, []string{"a", "c", "b"}, lru.orderedKeysForTest())
+	})
+	t.Run("break", func(t *testing.T) {
+		lru := New[string, int](nil)
+		lru.Add("a", 1)
+		lru.Add("b", 2)
+		lru.Add("c", 3)
+		var keys []string
+
+		lru.All()(func(k string, _ int) bool {
+			keys = append(keys, k)
+			return k != "b"
+		})
+
+		assert.Equal(t, []string{"c", "b"}, keys)
+	})
+	t.Run("remove_current", func(t *testing.T) {
+		lru := New[string, int](nil)
+		lru.Add("a", 1)
+		lru.Add("b", 2)
+		lru.Add("c", 3)
+
+		lru.All()(func(k string, _ int) bool {
+			lru.Remove(k)
+			return true
+		})
+
+		assert.Equal(t, 0, lru.Len())
+	})
+	t.Run("copy_on_get", func(t *testing.T) {
+		lru := New[string, []int](nil)
+		lru.CopyOnGet = func(v []int) []int {
+			return append([]int(nil), v...)
+		}
+		lru.Add("a", []int{1})
+
+		lru.All()(func(_ string, v []int) bool {
+			v[0] = 100
+			return true
+		})
+		value, _ := lru.Peek("a")
+
+		assert.Equal(t, []int{1}, value)
+	})
+}
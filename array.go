@@ -0,0 +1,125 @@
+// Copyright 2022 The policy-lru Authors. All rights reserved.
+//
+// Use of this source code is governed by the Apache License, Version
+// 2.0 (the "License"); you may not use this file except in compliance
+// with the License. You may find a copy of the license in the file
+// LICENSE or at  http://www.apache.org/licenses/LICENSE-2.0.
+
+package policylru
+
+// Cacher is the basic set of cache operations common to the cache
+// implementations in this package.
+type Cacher[Key comparable, Value any] interface {
+	// Add adds a value to the cache.
+	Add(k Key, v Value)
+	// Get looks up a key's value from the cache.
+	Get(k Key) (v Value, hit bool)
+	// Remove removes the provided key from the cache.
+	Remove(k Key) (removed bool)
+	// Len returns the number of items in the cache.
+	Len() int
+	// Clear purges all stored items from the cache.
+	Clear()
+}
+
+type arrayEntry[Key, Value any] struct {
+	key   Key
+	value Value
+}
+
+// ArrayCache is a fixed-capacity LRU cache for a small number of
+// items. It is not safe for concurrent access.
+//
+// ArrayCache stores its items in a single array allocated when the
+// cache is created, ordered from most to least recently used, and
+// finds keys by scanning the array. For small capacities, up to a few
+// dozen items, this is typically faster than the map and linked list
+// used by Cache, and it does not allocate as items are added. For
+// larger capacities, the linear scan makes it slower than Cache.
+//
+// ArrayCache evicts the least recently used item when a new key is
+// added to a full cache. It has no pluggable Policy or Handler.
+type ArrayCache[Key comparable, Value any] struct {
+	entries []arrayEntry[Key, Value]
+}
+
+// NewArrayCache creates a new ArrayCache which holds at most capacity
+// items. If capacity is zero or negative, the cache holds nothing.
+func NewArrayCache[Key comparable, Value any](capacity int) *ArrayCache[Key, Value] {
+	if capacity < 0 {
+		capacity = 0
+	}
+	return &ArrayCache[Key, Value]{
+		entries: make([]arrayEntry[Key, Value], 0, capacity),
+	}
+}
+
+func (c *ArrayCache[Key, Value]) find(k Key) int {
+	for i := range c.entries {
+		if c.entries[i].key == k {
+			return i
+		}
+	}
+	return -1
+}
+
+// moveToFront moves the entry at index i to index 0, shifting the
+// entries before it back by one.
+func (c *ArrayCache[Key, Value]) moveToFront(i int) {
+	e := c.entries[i]
+	copy(c.entries[1:i+1], c.entries[:i])
+	c.entries[0] = e
+}
+
+// Add adds a value to the cache, evicting the least recently used item
+// if the cache is full.
+func (c *ArrayCache[Key, Value]) Add(k Key, v Value) {
+	if i := c.find(k); i >= 0 {
+		c.entries[i].value = v
+		c.moveToFront(i)
+		return
+	}
+	if cap(c.entries) == 0 {
+		return
+	}
+	if len(c.entries) < cap(c.entries) {
+		c.entries = c.entries[:len(c.entries)+1]
+	}
+	c.entries[len(c.entries)-1] = arrayEntry[Key, Value]{key: k, value: v}
+	c.moveToFront(len(c.entries) - 1)
+}
+
+// Get looks up a key's value from the cache.
+func (c *ArrayCache[Key, Value]) Get(k Key) (v Value, hit bool) {
+	if i := c.find(k); i >= 0 {
+		v, hit = c.entries[i].value, true
+		c.moveToFront(i)
+	}
+	return
+}
+
+// Remove removes the provided key from the cache.
+func (c *ArrayCache[Key, Value]) Remove(k Key) (removed bool) {
+	i := c.find(k)
+	if i < 0 {
+		return false
+	}
+	n := len(c.entries) - 1
+	copy(c.entries[i:], c.entries[i+1:])
+	c.entries[n] = arrayEntry[Key, Value]{}
+	c.entries = c.entries[:n]
+	return true
+}
+
+// Len returns the number of items in the cache.
+func (c *ArrayCache[Key, Value]) Len() int {
+	return len(c.entries)
+}
+
+// Clear purges all stored items from the cache.
+func (c *ArrayCache[Key, Value]) Clear() {
+	for i := range c.entries {
+		c.entries[i] = arrayEntry[Key, Value]{}
+	}
+	c.entries = c.entries[:0]
+}
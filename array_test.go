@@ -0,0 +1,104 @@
+// Copyright 2022 The policy-lru Authors. All rights reserved.
+//
+// Use of this source code is governed by the Apache License, Version
+// 2.0 (the "License"); you may not use this file except in compliance
+// with the License. You may find a copy of the license in the file
+// LICENSE or at  http://www.apache.org/licenses/LICENSE-2.0.
+
+package policylru
+
+import (
+	"strconv"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+var (
+	_ Cacher[string, int] = &Cache[string, int]{}
+	_ Cacher[string, int] = &ArrayCache[string, int]{}
+)
+
+func TestArrayCache(t *testing.T) {
+	t.Run("lru_eviction", func(t *testing.T) {
+		lru := NewArrayCache[string, int](3)
+
+		lru.Add("a", 1)
+		lru.Add("b", 2)
+		lru.Add("c", 3)
+		lru.Get("a")
+		lru.Add("d", 4)
+		lru.Add("c", 30)
+		lru.Add("e", 5)
+		_, okB := lru.Get("b")
+		_, okA := lru.Get("a")
+		valueC, okC := lru.Get("c")
+
+		assert.Equal(t, 3, lru.Len())
+		assert.False(t, okB)
+		assert.False(t, okA)
+		assert.True(t, okC)
+		assert.Equal(t, 30, valueC)
+	})
+
+	t.Run("remove_and_clear", func(t *testing.T) {
+		lru := NewArrayCache[string, int](3)
+
+		lru.Add("a", 1)
+		lru.Add("b", 2)
+		shouldBeTrue := lru.Remove("a")
+		shouldBeFalse := lru.Remove("a")
+		value, ok := lru.Get("b")
+
+		assert.True(t, shouldBeTrue)
+		assert.False(t, shouldBeFalse)
+		assert.True(t, ok)
+		assert.Equal(t, 2, value)
+		assert.Equal(t, 1, lru.Len())
+
+		lru.Clear()
+
+		assert.Equal(t, 0, lru.Len())
+	})
+
+	t.Run("zero_capacity", func(t *testing.T) {
+		lru := NewArrayCache[string, int](0)
+
+		lru.Add("a", 1)
+		_, ok := lru.Get("a")
+
+		assert.Equal(t, 0, lru.Len())
+		assert.False(t, ok)
+	})
+}
+
+func benchmarkCacher(b *testing.B, c Cacher[string, int], capacity int) {
+	keys := make([]string, 2*capacity)
+	for i := range keys {
+		keys[i] = strconv.Itoa(i)
+	}
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		k := keys[(i*7)%len(keys)]
+		if _, ok := c.Get(k); !ok {
+			c.Add(k, i)
+		}
+	}
+}
+
+func BenchmarkArrayCache(b *testing.B) {
+	for _, capacity := range []int{8, 64} {
+		b.Run(strconv.Itoa(capacity), func(b *testing.B) {
+			benchmarkCacher(b, NewArrayCache[string, int](capacity), capacity)
+		})
+	}
+}
+
+func BenchmarkCache(b *testing.B) {
+	for _, capacity := range []int{8, 64} {
+		b.Run(strconv.Itoa(capacity), func(b *testing.B) {
+			benchmarkCacher(b, New[string, int](MaxCount[string, int](capacity)), capacity)
+		})
+	}
+}
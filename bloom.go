@@ -0,0 +1,91 @@
+// Copyright 2022 The policy-lru Authors. All rights reserved.
+//
+// Use of this source code is governed by the Apache License, Version
+// 2.0 (the "License"); you may not use this file except in compliance
+// with the License. You may find a copy of the license in the file
+// LICENSE or at  http://www.apache.org/licenses/LICENSE-2.0.
+
+package policylru
+
+// BloomFilter is a probabilistic set of keys which can say for certain
+// that a key was never added to it, but can only say that a key was
+// probably added. Installed as the Bloom field of a Cache, it records
+// every key ever added to the cache, so that lookups of keys the cache
+// has never seen can be answered without consulting the cache.
+//
+// A BloomFilter with m bits and k hash functions which has recorded n
+// distinct keys reports a key it has not seen as possibly present with
+// a false positive probability of about (1 - e^(-kn/m))^k. For example,
+// 10 bits and 7 hash functions per expected key give a false positive
+// rate of about 1%. The memory used is fixed at m bits regardless of
+// the number of keys recorded, and since keys cannot be deleted from a
+// BloomFilter, the false positive rate grows as keys are added. Use
+// Reset to start over.
+type BloomFilter[Key any] struct {
+	bits   []uint64
+	m      uint64
+	k      int
+	hashFn func(Key) uint64
+}
+
+// NewBloomFilter creates a BloomFilter with the given number of bits,
+// using the given number of hash functions, all derived from hash.
+//
+// The hash function should distribute keys uniformly over the full
+// range of uint64. NewBloomFilter panics if bits or hashes is not
+// positive.
+func NewBloomFilter[Key any](bits, hashes int, hash func(Key) uint64) *BloomFilter[Key] {
+	if bits <= 0 || hashes <= 0 {
+		panic("policylru: bloom filter bits and hashes must be positive")
+	}
+	return &BloomFilter[Key]{
+		bits:   make([]uint64, (bits+63)/64),
+		m:      uint64(bits),
+		k:      hashes,
+		hashFn: hash,
+	}
+}
+
+// Add records the key k in the filter.
+func (f *BloomFilter[Key]) Add(k Key) {
+	h1, h2 := f.hash(k)
+	for i := 0; i < f.k; i++ {
+		j := (h1 + uint64(i)*h2) % f.m
+		f.bits[j/64] |= 1 << (j % 64)
+	}
+}
+
+// MayContain returns false if the key k has definitely never been added
+// to the filter, and true if it probably has.
+func (f *BloomFilter[Key]) MayContain(k Key) bool {
+	h1, h2 := f.hash(k)
+	for i := 0; i < f.k; i++ {
+		j := (h1 + uint64(i)*h2) % f.m
+		if f.bits[j/64]&(1<<(j%64)) == 0 {
+			return false
+		}
+	}
+	return true
+}
+
+// Reset removes all keys from the filter.
+func (f *BloomFilter[Key]) Reset() {
+	for i := range f.bits {
+		f.bits[i] = 0
+	}
+}
+
+// hash derives the two base hashes used to simulate k independent hash
+// functions by double hashing.
+func (f *BloomFilter[Key]) hash(k Key) (uint64, uint64) {
+	h := f.hashFn(k)
+	// The second hash is a 64-bit finalizer (from MurmurHash3) applied
+	// to the first, forced odd so that it is never zero.
+	h2 := h
+	h2 ^= h2 >> 33
+	h2 *= 0xff51afd7ed558ccd
+	h2 ^= h2 >> 33
+	h2 *= 0xc4ceb9fe1a85ec53
+	h2 ^= h2 >> 33
+	return h, h2 | 1
+}
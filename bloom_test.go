@@ -0,0 +1,70 @@
+// Copyright 2022 The policy-lru Authors. All rights reserved.
+//
+// Use of this source code is governed by the Apache License, Version
+// 2.0 (the "License"); you may not use this file except in compliance
+// with the License. You may find a copy of the license in the file
+// LICENSE or at  http://www.apache.org/licenses/LICENSE-2.0.
+
+package policylru
+
+import (
+	"hash/fnv"
+	"strconv"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func fnvHash(s string) uint64 {
+	h := fnv.New64a()
+	_, _ = h.Write([]byte(s))
+	return h.Sum64()
+}
+
+func TestBloomFilter(t *testing.T) {
+	t.Run("no_false_negatives", func(t *testing.T) {
+		f := NewBloomFilter[string](1000, 7, fnvHash)
+
+		for i := 0; i < 100; i++ {
+			f.Add(strconv.Itoa(i))
+		}
+
+		for i := 0; i < 100; i++ {
+			assert.True(t, f.MayContain(strconv.Itoa(i)))
+		}
+		var falsePositives int
+		for i := 100; i < 10100; i++ {
+			if f.MayContain(strconv.Itoa(i)) {
+				falsePositives++
+			}
+		}
+		assert.Less(t, falsePositives, 300)
+
+		f.Reset()
+
+		assert.False(t, f.MayContain("0"))
+	})
+
+	t.Run("invalid", func(t *testing.T) {
+		assert.Panics(t, func() { NewBloomFilter[string](0, 1, fnvHash) })
+		assert.Panics(t, func() { NewBloomFilter[string](1, 0, fnvHash) })
+	})
+
+	t.Run("cache", func(t *testing.T) {
+		lru := New[string, int](MaxCount[string, int](1))
+		lru.Bloom = NewBloomFilter[string](1000, 7, fnvHash)
+
+		assert.True(t, lru.DefinitelyAbsent("foo"))
+		lru.Add("foo", 1)
+		lru.Add("bar", 2)
+		_, ok := lru.Get("foo")
+		value, ok2 := lru.Get("bar")
+
+		assert.False(t, lru.DefinitelyAbsent("foo"))
+		assert.False(t, lru.DefinitelyAbsent("bar"))
+		assert.True(t, lru.DefinitelyAbsent("baz"))
+		assert.False(t, ok)
+		assert.True(t, ok2)
+		assert.Equal(t, 2, value)
+	})
+}
@@ -7,14 +7,1378 @@
 
 package policylru
 
+import (
+	"container/list"
+	"fmt"
+	"time"
+)
+
+// countLimiter is implemented by built-in policies which limit the
+// number of keys in the cache, so that the Cache can recognize when
+// the limit leaves no room for any key at all.
+type countLimiter interface {
+	maxCount() int
+}
+
+// bounder is implemented by built-in policies which wrap other
+// policies, so that the Cache can tell whether any wrapped policy can
+// evict anything.
+type bounder interface {
+	bounded() bool
+}
+
+// admitter is implemented by built-in policies which track the cache
+// through Handler events, so that the Cache can ask them whether a new
+// item would fit before it is added, and they have seen it.
+type admitter[Key, Value any] interface {
+	// fits reports whether the policy would evict nothing from a cache
+	// of n items made by adding the new item k with the value v.
+	fits(k Key, v Value, n int) bool
+}
+
+// bounded reports whether the policy p can evict anything.
+func bounded[Key, Value any](p Policy[Key, Value]) bool {
+	if p == nil {
+		return false
+	}
+	if b, ok := p.(bounder); ok {
+		return b.bounded()
+	}
+	return true
+}
+
+// compositeSelector is implemented by built-in policies which combine
+// other policies, so that the Cache can let them choose the items to
+// evict when any policy they combine implements Selector. The Cache
+// only calls selectFrom if selects returns true.
+type compositeSelector[Key, Value any] interface {
+	selects() bool
+	selectFrom(n int, view cacheView[Key, Value]) (Key, bool)
+	// simulates reports whether every policy combined can choose from
+	// a view in which some items are treated as removed.
+	simulates() bool
+}
+
+// cacheView gives a compositeSelector read access to the items in the
+// cache.
+type cacheView[Key, Value any] interface {
+	// oldest returns the least recently used item which is not a
+	// pending eviction.
+	oldest() (Key, Value, bool)
+	// value returns the value of the item with key k.
+	value(k Key) (Value, bool)
+	// excluded returns a function reporting whether the item with key k
+	// is to be treated as removed, or nil if no item is.
+	excluded() func(k Key) bool
+}
+
+// excludingSelector is implemented by built-in Selectors which can
+// choose an item as if some items were already removed from the cache,
+// so that SurvivingKeys can simulate several evictions without
+// changing their state. Select(n) is selectExcluding(n, nil).
+type excludingSelector[Key any] interface {
+	selectExcluding(n int, gone func(k Key) bool) (Key, bool)
+}
+
+// attributor is implemented by the built-in policies which combine
+// other policies, so that the Cache can tell an EvictionHandler which
+// of them decided an eviction.
+type attributor[Key, Value any] interface {
+	// attribute returns the index of the policy which decided to evict
+	// the item with key k from a cache with n items.
+	attribute(k Key, n int, view cacheView[Key, Value]) int
+}
+
+// simulable reports whether the choices of the policy p can be
+// simulated by SurvivingKeys.
+func simulable[Key, Value any](p Policy[Key, Value]) bool {
+	if cs, ok := p.(compositeSelector[Key, Value]); ok {
+		return cs.simulates()
+	}
+	if _, ok := p.(Selector[Key]); ok {
+		_, ok = p.(excludingSelector[Key])
+		return ok
+	}
+	return true
+}
+
+// selecting reports whether the policy p chooses the items to evict
+// itself, rather than deciding whether to evict the oldest item.
+func selecting[Key, Value any](p Policy[Key, Value]) bool {
+	if cs, ok := p.(compositeSelector[Key, Value]); ok {
+		return cs.selects()
+	}
+	_, ok := p.(Selector[Key])
+	return ok
+}
+
+// selection returns the key of the item the policy p would evict from
+// a cache with n items, or false if p would evict nothing.
+func selection[Key, Value any](p Policy[Key, Value], n int, view cacheView[Key, Value]) (k Key, ok bool) {
+	if p == nil {
+		return
+	}
+	if cs, is := p.(compositeSelector[Key, Value]); is && cs.selects() {
+		return cs.selectFrom(n, view)
+	}
+	if es, is := p.(excludingSelector[Key]); is {
+		return es.selectExcluding(n, view.excluded())
+	}
+	if s, is := p.(Selector[Key]); is {
+		return s.Select(n)
+	}
+	k, v, ok := view.oldest()
+	if ok && p.Evict(k, v, n) {
+		return k, true
+	}
+	var zero Key
+	return zero, false
+}
+
 type maxCountPolicy[Key, Value any] int
 
 func (p maxCountPolicy[Key, Value]) Evict(_ Key, _ Value, n int) bool {
 	return n > int(p)
 }
 
+func (p maxCountPolicy[Key, Value]) maxCount() int {
+	return int(p)
+}
+
+// MaxCountVar is a Policy that evicts the oldest key from the Cache
+// when the number of keys in the cache exceeds a maximum count which
+// can be changed after the cache is created. Unlike the policy returned
+// by MaxCount, a MaxCountVar is held by pointer, so every cache using
+// the same MaxCountVar shares its count, except that Cache.Clone gives
+// the copy its own.
+//
+// Construct a MaxCountVar with NewMaxCountVar.
+type MaxCountVar[Key, Value any] struct {
+	max int
+}
+
+// NewMaxCountVar returns a MaxCountVar with the given maximum count.
+// As with MaxCount, a zero or negative count disables the cache.
+//
+// To resize a live cache, call SetMax and then the Cache's Evict method
+// to remove any surplus items:
+//
+//	p := policylru.NewMaxCountVar[string, int](1000)
+//	lru := policylru.New[string, int](p)
+//	...
+//	p.SetMax(500)
+//	lru.Evict()
+//
+// Like the Cache itself, a MaxCountVar is not safe for concurrent use:
+// calling SetMax while another goroutine uses the cache, including
+// running Evict, is a data race unless both calls are made under the
+// same lock, for example within SyncCache.Atomically.
+func NewMaxCountVar[Key, Value any](n int) *MaxCountVar[Key, Value] {
+	return &MaxCountVar[Key, Value]{max: n}
+}
+
+func (p *MaxCountVar[Key, Value]) Evict(_ Key, _ Value, n int) bool {
+	return n > p.max
+}
+
+// Max returns the current maximum count.
+func (p *MaxCountVar[Key, Value]) Max() int {
+	return p.max
+}
+
+// SetMax changes the maximum count. It does not evict any items, so
+// call the Cache's Evict method afterwards to shrink the cache to the
+// new count.
+func (p *MaxCountVar[Key, Value]) SetMax(n int) {
+	p.max = n
+}
+
+func (p *MaxCountVar[Key, Value]) maxCount() int {
+	return p.max
+}
+
+func (p *MaxCountVar[Key, Value]) clonePolicy() Policy[Key, Value] {
+	return &MaxCountVar[Key, Value]{max: p.max}
+}
+
+// Get returns the value of a parameter. The maximum count is the int
+// parameter "count".
+func (p *MaxCountVar[Key, Value]) Get(param string) (any, bool) {
+	if param != "count" {
+		return nil, false
+	}
+	return p.max, true
+}
+
+// Set changes the value of the "count" parameter.
+func (p *MaxCountVar[Key, Value]) Set(param string, value any) error {
+	if param != "count" {
+		return unknownParam(param)
+	}
+	n, err := intParam(param, value)
+	if err != nil {
+		return err
+	}
+	p.max = n
+	return nil
+}
+
 // MaxCount returns a Policy that evicts the oldest key from the Cache
 // when the number of keys in the cache exceeds the given maximum count.
+//
+// If n is zero or negative, the cache is disabled: Add does not store
+// new keys at all and generates no Handler events for them, rather than
+// storing each key and immediately evicting it.
+//
+// The returned policy is a value with a fixed count, so it can be
+// shared freely between caches. To resize a live cache, use a
+// MaxCountVar instead.
 func MaxCount[Key, Value any](n int) Policy[Key, Value] {
 	return maxCountPolicy[Key, Value](n)
 }
+
+type maxSizePolicy[Key, Value any] struct {
+	max   uint64
+	size  func(Value) uint64
+	total uint64
+}
+
+func (p *maxSizePolicy[Key, Value]) Evict(_ Key, _ Value, _ int) bool {
+	return p.total > p.max
+}
+
+func (p *maxSizePolicy[Key, Value]) clonePolicy() Policy[Key, Value] {
+	q := *p
+	return &q
+}
+
+func (p *maxSizePolicy[Key, Value]) fits(_ Key, v Value, _ int) bool {
+	return p.total+p.size(v) <= p.max
+}
+
+func (p *maxSizePolicy[Key, Value]) Added(_ Key, old, new Value, update bool) {
+	if update {
+		p.total -= p.size(old)
+	}
+	p.total += p.size(new)
+}
+
+func (p *maxSizePolicy[Key, Value]) Removed(_ Key, v Value) {
+	p.total -= p.size(v)
+}
+
+func (p *maxSizePolicy[Key, Value]) Get(param string) (any, bool) {
+	if param != "bytes" {
+		return nil, false
+	}
+	return p.max, true
+}
+
+func (p *maxSizePolicy[Key, Value]) Set(param string, value any) error {
+	if param != "bytes" {
+		return unknownParam(param)
+	}
+	if n, ok := value.(uint64); ok {
+		p.max = n
+		return nil
+	}
+	n, err := intParam(param, value)
+	if err != nil {
+		return err
+	}
+	if n < 0 {
+		return fmt.Errorf("policylru: parameter %q: must not be negative", param)
+	}
+	p.max = uint64(n)
+	return nil
+}
+
+// MaxSize returns a Policy that evicts the oldest key from the Cache
+// when the total size of the values in the cache, as measured by
+// sizeOf, exceeds maxBytes. Eviction continues until the total size is
+// at most maxBytes.
+//
+// The returned value tracks the total size of the cache using Handler
+// events, so it must be installed as both the policy and the handler of
+// the Cache, most easily by using NewTracked:
+//
+//	p := policylru.MaxSize[string, []byte](1<<20, sizeOf)
+//	lru := policylru.NewTracked[string, []byte](p)
+//
+// As with MaxCountAndSize, call Evict after an update that may have
+// grown the total size beyond maxBytes. The returned policy implements
+// Configurable, with the maximum total size available as the uint64
+// parameter "bytes".
+func MaxSize[Key, Value any](maxBytes uint64, sizeOf func(Value) uint64) PolicyHandler[Key, Value] {
+	return &maxSizePolicy[Key, Value]{max: maxBytes, size: sizeOf}
+}
+
+// MaxCountAndSizePolicy is a Policy that limits both the number of
+// keys in a Cache and the total size of its values. It also implements
+// Handler, which it uses to track the total size.
+//
+// Construct a MaxCountAndSizePolicy with MaxCountAndSize.
+type MaxCountAndSizePolicy[Key, Value any] struct {
+	maxCount int
+	maxSizePolicy[Key, Value]
+}
+
+// MaxCountAndSize returns a Policy that evicts the oldest key from the
+// Cache when either the number of keys in the cache exceeds maxCount
+// or the total size of the values in the cache, as measured by sizeOf,
+// exceeds maxBytes.
+//
+// The returned value tracks the total size of the cache using Handler
+// events, so it must be installed as both the policy and the handler of
+// the Cache, most easily by using NewTracked:
+//
+//	p := policylru.MaxCountAndSize[string, []byte](1000, 1<<20, sizeOf)
+//	lru := policylru.NewTracked[string, []byte](p)
+//
+// Since the Cache does not run its eviction policy when Add updates
+// the value of an existing key, call Evict after an update that may
+// have grown the total size beyond maxBytes.
+func MaxCountAndSize[Key, Value any](maxCount int, maxBytes uint64, sizeOf func(Value) uint64) *MaxCountAndSizePolicy[Key, Value] {
+	return &MaxCountAndSizePolicy[Key, Value]{
+		maxCount:      maxCount,
+		maxSizePolicy: maxSizePolicy[Key, Value]{max: maxBytes, size: sizeOf},
+	}
+}
+
+func (p *MaxCountAndSizePolicy[Key, Value]) Evict(k Key, v Value, n int) bool {
+	return n > p.maxCount || p.maxSizePolicy.Evict(k, v, n)
+}
+
+func (p *MaxCountAndSizePolicy[Key, Value]) clonePolicy() Policy[Key, Value] {
+	q := *p
+	return &q
+}
+
+func (p *MaxCountAndSizePolicy[Key, Value]) fits(k Key, v Value, n int) bool {
+	return n <= p.maxCount && p.maxSizePolicy.fits(k, v, n)
+}
+
+// Get returns the value of a parameter. The maximum count is the int
+// parameter "count" and the maximum total size is the uint64 parameter
+// "bytes".
+func (p *MaxCountAndSizePolicy[Key, Value]) Get(param string) (any, bool) {
+	switch param {
+	case "count":
+		return p.maxCount, true
+	default:
+		return p.maxSizePolicy.Get(param)
+	}
+}
+
+// Set changes the value of the "count" or "bytes" parameter.
+func (p *MaxCountAndSizePolicy[Key, Value]) Set(param string, value any) error {
+	switch param {
+	case "count":
+		n, err := intParam(param, value)
+		if err != nil {
+			return err
+		}
+		p.maxCount = n
+	default:
+		return p.maxSizePolicy.Set(param, value)
+	}
+	return nil
+}
+
+// PartitionedPolicy is a Policy which divides the keys of a Cache into
+// partitions and limits the number of keys in each partition
+// separately, as if each partition were a separate cache. It also
+// implements Selector, Handler and PromotionHandler, which it uses to
+// track the recency of the keys in each partition.
+//
+// Construct a PartitionedPolicy with Partitioned.
+type PartitionedPolicy[Key comparable, Value any, Part comparable] struct {
+	partition func(Key) Part
+	limit     func(Part) int
+	order     []Part
+	parts     map[Part]*list.List
+	elems     map[Key]*list.Element
+}
+
+// Partitioned returns a Policy that assigns each key in the Cache to
+// the partition returned by partition, and evicts the least recently
+// used key in a partition when the number of keys in the partition
+// exceeds limit(p). This allows one Cache to enforce, for example,
+// per-tenant quotas.
+//
+// The Cache's Len is the total number of keys in all partitions, and
+// its recency order, as seen by other methods, is still the global
+// order of use across all partitions. Eviction only ever removes a key
+// from a partition which is over its limit, even if a key in another
+// partition is older. If more than one partition is over its limit,
+// they are trimmed in the order the partitions were first seen. A
+// partition is forgotten when its last key is removed, so if it later
+// gets keys again, it is ordered as if seen for the first time.
+//
+// The returned value tracks the keys in each partition using Handler
+// events, so it must be installed as both the policy and the handler
+// of the Cache, most easily by using NewTracked.
+func Partitioned[Key comparable, Value any, Part comparable](partition func(Key) Part, limit func(Part) int) *PartitionedPolicy[Key, Value, Part] {
+	return &PartitionedPolicy[Key, Value, Part]{
+		partition: partition,
+		limit:     limit,
+		parts:     make(map[Part]*list.List),
+		elems:     make(map[Key]*list.Element),
+	}
+}
+
+// Evict reports whether the partition of key k is over its limit.
+func (p *PartitionedPolicy[Key, Value, Part]) Evict(k Key, _ Value, _ int) bool {
+	part := p.partition(k)
+	l := p.parts[part]
+	return l != nil && l.Len() > p.limit(part)
+}
+
+func (p *PartitionedPolicy[Key, Value, Part]) fits(k Key, _ Value, _ int) bool {
+	part := p.partition(k)
+	n := 1
+	if l := p.parts[part]; l != nil {
+		n += l.Len()
+	}
+	return n <= p.limit(part)
+}
+
+// Select returns the least recently used key in the first partition
+// which is over its limit.
+func (p *PartitionedPolicy[Key, Value, Part]) Select(n int) (k Key, ok bool) {
+	return p.selectExcluding(n, nil)
+}
+
+func (p *PartitionedPolicy[Key, Value, Part]) selectExcluding(_ int, gone func(Key) bool) (k Key, ok bool) {
+	for _, part := range p.order {
+		l := p.parts[part]
+		if gone == nil {
+			if l.Len() > p.limit(part) {
+				return l.Back().Value.(Key), true
+			}
+			continue
+		}
+		var oldest *list.Element
+		count := l.Len()
+		for ele := l.Back(); ele != nil; ele = ele.Prev() {
+			if gone(ele.Value.(Key)) {
+				count--
+			} else if oldest == nil {
+				oldest = ele
+			}
+		}
+		if count > p.limit(part) {
+			return oldest.Value.(Key), true
+		}
+	}
+	return
+}
+
+func (p *PartitionedPolicy[Key, Value, Part]) Added(k Key, _, _ Value, update bool) {
+	if update {
+		p.Promoted(k, -1, 0)
+		return
+	}
+	part := p.partition(k)
+	l := p.parts[part]
+	if l == nil {
+		l = list.New()
+		p.parts[part] = l
+		p.order = append(p.order, part)
+	}
+	p.elems[k] = l.PushFront(k)
+}
+
+// Removed forgets the key k, and forgets its partition too if k was
+// the last key in it, so that partitions which come and go, such as
+// those of short-lived tenants, do not accumulate.
+func (p *PartitionedPolicy[Key, Value, Part]) Removed(k Key, _ Value) {
+	ele, ok := p.elems[k]
+	if !ok {
+		return
+	}
+	delete(p.elems, k)
+	part := p.partition(k)
+	l := p.parts[part]
+	l.Remove(ele)
+	if l.Len() > 0 {
+		return
+	}
+	delete(p.parts, part)
+	for i := range p.order {
+		if p.order[i] == part {
+			p.order = append(p.order[:i], p.order[i+1:]...)
+			break
+		}
+	}
+}
+
+func (p *PartitionedPolicy[Key, Value, Part]) Promoted(k Key, _, _ int) {
+	if ele, ok := p.elems[k]; ok {
+		p.parts[p.partition(k)].MoveToFront(ele)
+	}
+}
+
+// AdaptivePolicy is a Policy which limits the number of keys in a
+// Cache, like MaxCount, but adjusts the limit between a minimum and a
+// maximum according to the cache's hit ratio. It also implements
+// Handler and LookupHandler, which it uses to track the number of keys
+// in the cache and the lookups made.
+//
+// The limit is adjusted after every Window lookups, based on the hit
+// ratio of those lookups:
+//   - if the hit ratio is at least High, and the cache is full, the
+//     limit is raised by Step, since the cache is useful and a larger
+//     cache may be more useful still;
+//   - if the hit ratio is at most Low, the limit is lowered by Step,
+//     since the cache is not earning the memory it uses;
+//   - otherwise the limit is left alone.
+//
+// The limit never goes below the minimum or above the maximum. When the
+// limit is lowered, the surplus keys are evicted on the next call to
+// Add or Evict.
+//
+// Construct an AdaptivePolicy with Adaptive. The exported fields may be
+// changed before the policy is used.
+type AdaptivePolicy[Key, Value any] struct {
+	// Window is the number of lookups between adjustments of the limit.
+	Window int
+	// High is the hit ratio at or above which the limit is raised.
+	High float64
+	// Low is the hit ratio at or below which the limit is lowered.
+	Low float64
+	// Step is the amount by which the limit is raised or lowered.
+	Step int
+
+	min, max, limit int
+	count           int
+	lookups, hits   int
+}
+
+// Adaptive returns a Policy that evicts the oldest key from the Cache
+// when the number of keys in the cache exceeds a limit which adapts to
+// the cache's hit ratio, starting at min and never exceeding max. See
+// AdaptivePolicy for a description of the adaptation.
+//
+// The returned policy has a Window of 1000 lookups, a High ratio of
+// 0.8, a Low ratio of 0.2, and a Step of one tenth of the difference
+// between max and min, or 1 if that is smaller.
+//
+// The returned value tracks the cache using Handler events, so it must
+// be installed as both the policy and the handler of the Cache, most
+// easily by using NewTracked.
+//
+// Adaptive panics if min is not positive or max is less than min.
+func Adaptive[Key, Value any](min, max int) *AdaptivePolicy[Key, Value] {
+	if min <= 0 || max < min {
+		panic("policylru: Adaptive requires 0 < min <= max")
+	}
+	step := (max - min) / 10
+	if step < 1 {
+		step = 1
+	}
+	return &AdaptivePolicy[Key, Value]{
+		Window: 1000,
+		High:   0.8,
+		Low:    0.2,
+		Step:   step,
+		min:    min,
+		max:    max,
+		limit:  min,
+	}
+}
+
+// Limit returns the current limit on the number of keys.
+func (p *AdaptivePolicy[Key, Value]) Limit() int {
+	return p.limit
+}
+
+// Evict reports whether the number of keys exceeds the current limit.
+func (p *AdaptivePolicy[Key, Value]) clonePolicy() Policy[Key, Value] {
+	q := *p
+	return &q
+}
+
+func (p *AdaptivePolicy[Key, Value]) Evict(_ Key, _ Value, n int) bool {
+	return n > p.limit
+}
+
+func (p *AdaptivePolicy[Key, Value]) Added(_ Key, _, _ Value, update bool) {
+	if !update {
+		p.count++
+	}
+}
+
+func (p *AdaptivePolicy[Key, Value]) Removed(_ Key, _ Value) {
+	p.count--
+}
+
+// Get returns the value of a parameter. The bounds on the limit are the
+// int parameters "min" and "max", and the current limit is the int
+// parameter "limit".
+func (p *AdaptivePolicy[Key, Value]) Get(param string) (any, bool) {
+	switch param {
+	case "min":
+		return p.min, true
+	case "max":
+		return p.max, true
+	case "limit":
+		return p.limit, true
+	default:
+		return nil, false
+	}
+}
+
+// Set changes the value of the "min" or "max" parameter, moving the
+// current limit within the new bounds if necessary. The current limit
+// cannot be set directly.
+func (p *AdaptivePolicy[Key, Value]) Set(param string, value any) error {
+	if param != "min" && param != "max" {
+		return unknownParam(param)
+	}
+	n, err := intParam(param, value)
+	if err != nil {
+		return err
+	}
+	min, max := p.min, p.max
+	if param == "min" {
+		min = n
+	} else {
+		max = n
+	}
+	if min <= 0 || max < min {
+		return fmt.Errorf("policylru: parameter %q: requires 0 < min <= max", param)
+	}
+	p.min, p.max = min, max
+	if p.limit < min {
+		p.limit = min
+	} else if p.limit > max {
+		p.limit = max
+	}
+	return nil
+}
+
+// LookedUp records a lookup and adjusts the limit at the end of each
+// window of lookups.
+func (p *AdaptivePolicy[Key, Value]) LookedUp(_ Key, hit bool) {
+	p.lookups++
+	if hit {
+		p.hits++
+	}
+	if p.lookups < p.Window {
+		return
+	}
+	ratio := float64(p.hits) / float64(p.lookups)
+	p.lookups, p.hits = 0, 0
+	switch {
+	case ratio >= p.High && p.count >= p.limit:
+		p.limit += p.Step
+		if p.limit > p.max {
+			p.limit = p.max
+		}
+	case ratio <= p.Low:
+		p.limit -= p.Step
+		if p.limit < p.min {
+			p.limit = p.min
+		}
+	}
+}
+
+type preferringPolicy[Key, Value any] struct {
+	pred  func(k Key, v Value) bool
+	inner Policy[Key, Value]
+}
+
+// EvictPreferring returns a Policy which decides when to evict items
+// using the policy inner, but evicts the least recently used item
+// matching pred, if there is one, before falling back to the oldest
+// item. This allows items which are cheap to lose, for example because
+// they have already been saved elsewhere, to be shed first.
+//
+// The returned policy implements Preferrer, so each eviction may scan
+// every item in the cache to find one matching pred. Its cost is
+// therefore proportional to the number of items in the cache.
+//
+// If inner implements Selector, such as LFU or ExpireAfter, the items
+// to evict are those inner selects, and pred is not used.
+//
+// The returned value also implements Handler, LookupHandler and
+// PromotionHandler, forwarding events to inner if inner implements the
+// same interface, so that a policy which tracks the cache with Handler
+// events can be wrapped and installed with NewTracked.
+func EvictPreferring[Key, Value any](pred func(k Key, v Value) bool, inner Policy[Key, Value]) PolicyHandler[Key, Value] {
+	return &preferringPolicy[Key, Value]{pred: pred, inner: inner}
+}
+
+func (p *preferringPolicy[Key, Value]) Evict(k Key, v Value, n int) bool {
+	return p.inner != nil && p.inner.Evict(k, v, n)
+}
+
+func (p *preferringPolicy[Key, Value]) bounded() bool {
+	return bounded(p.inner)
+}
+
+func (p *preferringPolicy[Key, Value]) Prefer(k Key, v Value) bool {
+	return p.pred(k, v)
+}
+
+func (p *preferringPolicy[Key, Value]) selects() bool {
+	return selecting(p.inner)
+}
+
+func (p *preferringPolicy[Key, Value]) selectFrom(n int, view cacheView[Key, Value]) (Key, bool) {
+	return selection(p.inner, n, view)
+}
+
+func (p *preferringPolicy[Key, Value]) simulates() bool {
+	return simulable(p.inner)
+}
+
+func (p *preferringPolicy[Key, Value]) Added(k Key, old, new Value, update bool) {
+	policies[Key, Value]{p.inner}.Added(k, old, new, update)
+}
+
+func (p *preferringPolicy[Key, Value]) Removed(k Key, v Value) {
+	policies[Key, Value]{p.inner}.Removed(k, v)
+}
+
+func (p *preferringPolicy[Key, Value]) LookedUp(k Key, hit bool) {
+	policies[Key, Value]{p.inner}.LookedUp(k, hit)
+}
+
+func (p *preferringPolicy[Key, Value]) Promoted(k Key, fromRank, toRank int) {
+	policies[Key, Value]{p.inner}.Promoted(k, fromRank, toRank)
+}
+
+// MaxCountMinRetainPolicy is a Policy which limits the number of keys
+// in a Cache, like MaxCount, but never evicts a key which was added
+// less than a minimum retention time ago. It also implements Retainer,
+// and Handler, which it uses to track the time each key was added.
+// It implements Configurable, with the maximum count available as the
+// int parameter "count" and the minimum retention time as the
+// time.Duration parameter "retain".
+//
+// Construct a MaxCountMinRetainPolicy with MaxCountMinRetain.
+type MaxCountMinRetainPolicy[Key comparable, Value any] struct {
+	// Clock optionally supplies the current time. If Clock is nil,
+	// time.Now is used.
+	Clock func() time.Time
+
+	maxCount  int
+	minRetain time.Duration
+	added     map[Key]time.Time
+}
+
+// MaxCountMinRetain returns a Policy that evicts the oldest keys from
+// the Cache when the number of keys in the cache exceeds maxCount, but
+// skips over any key added less than minRetain ago, leaving it in
+// place and evicting older keys instead. Updating the value of a key
+// does not restart its retention time.
+//
+// Since young keys are never evicted, the cache can exceed maxCount by
+// as many keys as were added within the last minRetain. The excess is
+// evicted by the first call to Add or Evict after the keys have aged.
+//
+// The returned value tracks the keys using Handler events, so it must
+// be installed as both the policy and the handler of the Cache, most
+// easily by using NewTracked.
+func MaxCountMinRetain[Key comparable, Value any](maxCount int, minRetain time.Duration) *MaxCountMinRetainPolicy[Key, Value] {
+	return &MaxCountMinRetainPolicy[Key, Value]{
+		maxCount:  maxCount,
+		minRetain: minRetain,
+		added:     make(map[Key]time.Time),
+	}
+}
+
+func (p *MaxCountMinRetainPolicy[Key, Value]) Evict(_ Key, _ Value, n int) bool {
+	return n > p.maxCount
+}
+
+// Retain reports whether the key k was added less than the minimum
+// retention time ago.
+func (p *MaxCountMinRetainPolicy[Key, Value]) Retain(k Key, _ Value) bool {
+	t, ok := p.added[k]
+	return ok && p.now().Sub(t) < p.minRetain
+}
+
+func (p *MaxCountMinRetainPolicy[Key, Value]) Added(k Key, _, _ Value, update bool) {
+	if !update {
+		p.added[k] = p.now()
+	}
+}
+
+func (p *MaxCountMinRetainPolicy[Key, Value]) Removed(k Key, _ Value) {
+	delete(p.added, k)
+}
+
+func (p *MaxCountMinRetainPolicy[Key, Value]) now() time.Time {
+	if p.Clock != nil {
+		return p.Clock()
+	}
+	return time.Now()
+}
+
+func (p *MaxCountMinRetainPolicy[Key, Value]) Get(param string) (any, bool) {
+	switch param {
+	case "count":
+		return p.maxCount, true
+	case "retain":
+		return p.minRetain, true
+	default:
+		return nil, false
+	}
+}
+
+func (p *MaxCountMinRetainPolicy[Key, Value]) Set(param string, value any) error {
+	switch param {
+	case "count":
+		n, err := intParam(param, value)
+		if err != nil {
+			return err
+		}
+		p.maxCount = n
+	case "retain":
+		d, err := durationParam(param, value)
+		if err != nil {
+			return err
+		}
+		p.minRetain = d
+	default:
+		return unknownParam(param)
+	}
+	return nil
+}
+
+func (p *MaxCountMinRetainPolicy[Key, Value]) clonePolicy() Policy[Key, Value] {
+	q := *p
+	q.added = make(map[Key]time.Time, len(p.added))
+	for k, t := range p.added {
+		q.added[k] = t
+	}
+	return &q
+}
+
+// ExpireAfterPolicy is a Policy which evicts keys a fixed time after
+// they were added. It implements Selector, so that it can evict
+// expired keys regardless of their recency, and Handler, which it uses
+// to track the time each key was added. It implements Configurable,
+// with the time to live available as the time.Duration parameter
+// "ttl".
+//
+// Construct an ExpireAfterPolicy with ExpireAfter.
+type ExpireAfterPolicy[Key comparable, Value any] struct {
+	// Clock optionally supplies the current time. If Clock is nil,
+	// time.Now is used.
+	Clock func() time.Time
+
+	ttl   time.Duration
+	order *list.List
+	added map[Key]*list.Element
+}
+
+type expiry[Key any] struct {
+	key   Key
+	added time.Time
+}
+
+// ExpireAfter returns a Policy that evicts each key from the Cache once
+// d has passed since it was added. Updating the value of a key restarts
+// its time.
+//
+// Expiration is lazy: expired keys are only evicted when the Cache runs
+// its policy, during Add or Evict, and until then Get still finds them.
+// To remove expired keys promptly, call Evict periodically, for example
+// from a background goroutine, taking care to synchronize access to the
+// cache. To hide an expired key from Get as soon as it expires, add it
+// with AddUntil instead.
+//
+// The returned value tracks the keys using Handler events, so it must
+// be installed as both the policy and the handler of the Cache, most
+// easily by using NewTracked.
+func ExpireAfter[Key comparable, Value any](d time.Duration) *ExpireAfterPolicy[Key, Value] {
+	return &ExpireAfterPolicy[Key, Value]{
+		ttl:   d,
+		order: list.New(),
+		added: make(map[Key]*list.Element),
+	}
+}
+
+// Evict reports whether the key k has expired.
+func (p *ExpireAfterPolicy[Key, Value]) Evict(k Key, _ Value, _ int) bool {
+	ele, ok := p.added[k]
+	return ok && p.expired(ele)
+}
+
+// Select returns the key added longest ago, if it has expired.
+func (p *ExpireAfterPolicy[Key, Value]) Select(n int) (k Key, ok bool) {
+	return p.selectExcluding(n, nil)
+}
+
+func (p *ExpireAfterPolicy[Key, Value]) selectExcluding(_ int, gone func(Key) bool) (k Key, ok bool) {
+	ele := p.order.Front()
+	for gone != nil && ele != nil && gone(ele.Value.(expiry[Key]).key) {
+		ele = ele.Next()
+	}
+	if ele == nil || !p.expired(ele) {
+		return
+	}
+	return ele.Value.(expiry[Key]).key, true
+}
+
+func (p *ExpireAfterPolicy[Key, Value]) Added(k Key, _, _ Value, _ bool) {
+	if ele, ok := p.added[k]; ok {
+		p.order.Remove(ele)
+	}
+	p.added[k] = p.order.PushBack(expiry[Key]{k, p.now()})
+}
+
+func (p *ExpireAfterPolicy[Key, Value]) Removed(k Key, _ Value) {
+	if ele, ok := p.added[k]; ok {
+		p.order.Remove(ele)
+		delete(p.added, k)
+	}
+}
+
+func (p *ExpireAfterPolicy[Key, Value]) expired(ele *list.Element) bool {
+	return p.now().Sub(ele.Value.(expiry[Key]).added) >= p.ttl
+}
+
+func (p *ExpireAfterPolicy[Key, Value]) now() time.Time {
+	if p.Clock != nil {
+		return p.Clock()
+	}
+	return time.Now()
+}
+
+func (p *ExpireAfterPolicy[Key, Value]) Get(param string) (any, bool) {
+	if param != "ttl" {
+		return nil, false
+	}
+	return p.ttl, true
+}
+
+// Set changes the value of the "ttl" parameter. The new time to live
+// applies to every key, counted from the time it was added, so
+// shortening it may expire keys at once.
+func (p *ExpireAfterPolicy[Key, Value]) Set(param string, value any) error {
+	if param != "ttl" {
+		return unknownParam(param)
+	}
+	d, err := durationParam(param, value)
+	if err != nil {
+		return err
+	}
+	p.ttl = d
+	return nil
+}
+
+func (p *ExpireAfterPolicy[Key, Value]) clonePolicy() Policy[Key, Value] {
+	q := *p
+	q.order = list.New()
+	q.added = make(map[Key]*list.Element, len(p.added))
+	for ele := p.order.Front(); ele != nil; ele = ele.Next() {
+		e := ele.Value.(expiry[Key])
+		q.added[e.key] = q.order.PushBack(e)
+	}
+	return &q
+}
+
+// LFUPolicy is a Policy which limits the number of keys in a Cache by
+// evicting the least frequently used key. It implements Selector, to
+// choose the key to evict, and Handler and LookupHandler, which it uses
+// to count the uses of each key. It implements Configurable, with the
+// maximum count available as the int parameter "count".
+//
+// Construct an LFUPolicy with LFU.
+type LFUPolicy[Key comparable, Value any] struct {
+	maxCount int
+	elems    map[Key]*list.Element
+	freqs    map[int]*list.List
+	newest   Key
+	isNewest bool
+}
+
+type lfuItem[Key any] struct {
+	key  Key
+	uses int
+}
+
+// LFU returns a Policy that evicts the least frequently used key from
+// the Cache when the number of keys in the cache exceeds maxCount.
+//
+// A key's use count starts at one when it is added, and goes up by one
+// each time Get or one of its variants finds it, or Add updates its
+// value. The count is forgotten when the key leaves the cache. If
+// several keys share the lowest count, the least recently used of them
+// is evicted, so LFU behaves like MaxCount among keys used equally
+// often. The key added most recently is only evicted if no other key
+// can be, since otherwise, having the lowest possible count, it would
+// always be evicted as soon as it was added to a full cache.
+//
+// The returned value counts uses of the keys using Handler and
+// LookupHandler events, so it must be installed as both the policy and
+// the handler of the Cache, most easily by using NewTracked.
+func LFU[Key comparable, Value any](maxCount int) *LFUPolicy[Key, Value] {
+	return &LFUPolicy[Key, Value]{
+		maxCount: maxCount,
+		elems:    make(map[Key]*list.Element),
+		freqs:    make(map[int]*list.List),
+	}
+}
+
+func (p *LFUPolicy[Key, Value]) Evict(_ Key, _ Value, n int) bool {
+	return n > p.maxCount
+}
+
+// Select returns the least recently used of the keys with the lowest
+// use count, if the cache has more than the maximum number of keys.
+func (p *LFUPolicy[Key, Value]) Select(n int) (k Key, ok bool) {
+	return p.selectExcluding(n, nil)
+}
+
+func (p *LFUPolicy[Key, Value]) selectExcluding(n int, gone func(Key) bool) (k Key, ok bool) {
+	if n <= p.maxCount || len(p.elems) == 0 {
+		return
+	}
+	var victim *list.Element
+	for uses, l := range p.freqs {
+		ele := l.Back()
+		for ele != nil {
+			k := ele.Value.(lfuItem[Key]).key
+			if !(p.isNewest && k == p.newest) && (gone == nil || !gone(k)) {
+				break
+			}
+			ele = ele.Prev()
+		}
+		if ele != nil && (victim == nil || uses < victim.Value.(lfuItem[Key]).uses) {
+			victim = ele
+		}
+	}
+	if victim == nil {
+		return p.newest, p.isNewest && (gone == nil || !gone(p.newest))
+	}
+	return victim.Value.(lfuItem[Key]).key, true
+}
+
+func (p *LFUPolicy[Key, Value]) Added(k Key, _, _ Value, update bool) {
+	p.use(k)
+	if !update {
+		p.newest, p.isNewest = k, true
+	}
+}
+
+func (p *LFUPolicy[Key, Value]) Removed(k Key, _ Value) {
+	if ele, ok := p.elems[k]; ok {
+		p.unlink(ele)
+		delete(p.elems, k)
+	}
+	if p.isNewest && k == p.newest {
+		var zero Key
+		p.newest, p.isNewest = zero, false
+	}
+}
+
+// LookedUp counts a use of the key k if it was found in the cache.
+func (p *LFUPolicy[Key, Value]) LookedUp(k Key, hit bool) {
+	if hit {
+		p.use(k)
+	}
+}
+
+// use moves the key k to the front, the most recently used end, of the
+// list of keys with its new use count.
+func (p *LFUPolicy[Key, Value]) use(k Key) {
+	uses := 1
+	if ele, ok := p.elems[k]; ok {
+		uses += ele.Value.(lfuItem[Key]).uses
+		p.unlink(ele)
+	}
+	l := p.freqs[uses]
+	if l == nil {
+		l = list.New()
+		p.freqs[uses] = l
+	}
+	p.elems[k] = l.PushFront(lfuItem[Key]{k, uses})
+}
+
+func (p *LFUPolicy[Key, Value]) unlink(ele *list.Element) {
+	uses := ele.Value.(lfuItem[Key]).uses
+	l := p.freqs[uses]
+	l.Remove(ele)
+	if l.Len() == 0 {
+		delete(p.freqs, uses)
+	}
+}
+
+func (p *LFUPolicy[Key, Value]) Get(param string) (any, bool) {
+	if param != "count" {
+		return nil, false
+	}
+	return p.maxCount, true
+}
+
+func (p *LFUPolicy[Key, Value]) Set(param string, value any) error {
+	if param != "count" {
+		return unknownParam(param)
+	}
+	n, err := intParam(param, value)
+	if err != nil {
+		return err
+	}
+	p.maxCount = n
+	return nil
+}
+
+func (p *LFUPolicy[Key, Value]) clonePolicy() Policy[Key, Value] {
+	q := *p
+	q.elems = make(map[Key]*list.Element, len(p.elems))
+	q.freqs = make(map[int]*list.List, len(p.freqs))
+	for uses, l := range p.freqs {
+		m := list.New()
+		for ele := l.Front(); ele != nil; ele = ele.Next() {
+			item := ele.Value.(lfuItem[Key])
+			q.elems[item.key] = m.PushBack(item)
+		}
+		q.freqs[uses] = m
+	}
+	return &q
+}
+
+// policies is a list of policies combined by a built-in policy. It
+// implements Handler, LookupHandler and PromotionHandler, forwarding
+// events to every policy in the list which implements the same
+// interface.
+type policies[Key, Value any] []Policy[Key, Value]
+
+func (ps policies[Key, Value]) Added(k Key, old, new Value, update bool) {
+	for _, p := range ps {
+		if h, ok := p.(Handler[Key, Value]); ok {
+			h.Added(k, old, new, update)
+		}
+	}
+}
+
+func (ps policies[Key, Value]) Removed(k Key, v Value) {
+	for _, p := range ps {
+		if h, ok := p.(Handler[Key, Value]); ok {
+			h.Removed(k, v)
+		}
+	}
+}
+
+func (ps policies[Key, Value]) LookedUp(k Key, hit bool) {
+	for _, p := range ps {
+		if lh, ok := p.(LookupHandler[Key]); ok {
+			lh.LookedUp(k, hit)
+		}
+	}
+}
+
+func (ps policies[Key, Value]) Promoted(k Key, fromRank, toRank int) {
+	for _, p := range ps {
+		if ph, ok := p.(PromotionHandler[Key]); ok {
+			ph.Promoted(k, fromRank, toRank)
+		}
+	}
+}
+
+// simulates reports whether the choices of every policy in the list
+// can be simulated.
+func (ps policies[Key, Value]) simulates() bool {
+	for _, p := range ps {
+		if p != nil && !simulable(p) {
+			return false
+		}
+	}
+	return true
+}
+
+// selects reports whether any policy in the list chooses the items to
+// evict itself.
+func (ps policies[Key, Value]) selects() bool {
+	for _, p := range ps {
+		if selecting(p) {
+			return true
+		}
+	}
+	return false
+}
+
+type andPolicy[Key, Value any] struct {
+	policies[Key, Value]
+}
+
+// And returns a Policy which evicts an item only if every one of the
+// given policies would evict it. The policies are consulted in order,
+// and consultation stops at the first policy which would keep the item.
+// A nil policy never evicts anything, and And with no policies never
+// evicts anything.
+//
+// If any of the policies implements Selector, such as ExpireAfter or
+// LFU, a policy which implements Selector would only evict the item it
+// selects, so And evicts the item selected by the first policy, or the
+// oldest item if the first policy is not a Selector, if every other
+// policy would evict that item too.
+//
+// The returned value also implements Handler, LookupHandler and
+// PromotionHandler, forwarding events to every policy which implements
+// the same interface, so that policies which track the cache with
+// Handler events can be combined and installed with NewTracked. A
+// Handler which implements EvictionHandler is told of each eviction,
+// attributed to the first policy.
+func And[Key, Value any](ps ...Policy[Key, Value]) PolicyHandler[Key, Value] {
+	return &andPolicy[Key, Value]{policies[Key, Value](ps)}
+}
+
+func (p *andPolicy[Key, Value]) Evict(k Key, v Value, n int) bool {
+	if len(p.policies) == 0 {
+		return false
+	}
+	for _, q := range p.policies {
+		if q == nil || !q.Evict(k, v, n) {
+			return false
+		}
+	}
+	return true
+}
+
+func (p *andPolicy[Key, Value]) selectFrom(n int, view cacheView[Key, Value]) (k Key, ok bool) {
+	if len(p.policies) == 0 {
+		return
+	}
+	if k, ok = selection(p.policies[0], n, view); !ok {
+		return
+	}
+	for _, q := range p.policies[1:] {
+		if !agrees(q, k, n, view) {
+			var zero Key
+			return zero, false
+		}
+	}
+	return
+}
+
+// agrees reports whether the policy p would evict the item with key k
+// from a cache with n items.
+func agrees[Key, Value any](p Policy[Key, Value], k Key, n int, view cacheView[Key, Value]) bool {
+	if p == nil {
+		return false
+	}
+	if selecting(p) {
+		s, ok := selection(p, n, view)
+		return ok && any(s) == any(k)
+	}
+	v, ok := view.value(k)
+	return ok && p.Evict(k, v, n)
+}
+
+// attribute attributes an eviction to the first policy, since every
+// policy agreed to it.
+func (p *andPolicy[Key, Value]) attribute(Key, int, cacheView[Key, Value]) int {
+	return 0
+}
+
+func (p *andPolicy[Key, Value]) bounded() bool {
+	for _, q := range p.policies {
+		if !bounded(q) {
+			return false
+		}
+	}
+	return len(p.policies) > 0
+}
+
+type orPolicy[Key, Value any] struct {
+	policies[Key, Value]
+}
+
+// Or returns a Policy which evicts an item if any one of the given
+// policies would evict it. The policies are consulted in order, and
+// consultation stops at the first policy which would evict the item.
+// A nil policy never evicts anything, and Or with no policies never
+// evicts anything.
+//
+// If any of the policies implements Selector, such as ExpireAfter or
+// LFU, each eviction removes the item selected by the first policy
+// which would evict anything: a Selector's selected item, or the oldest
+// item for any other policy. For example, Or(MaxCount(100),
+// ExpireAfter(d)) evicts the oldest items while there are more than 100
+// of them, and otherwise evicts expired items, however recently used.
+//
+// Like And, the returned value also implements Handler, LookupHandler
+// and PromotionHandler, forwarding events to every policy which
+// implements the same interface. A Handler which implements
+// EvictionHandler is told which of the policies decided each eviction.
+func Or[Key, Value any](ps ...Policy[Key, Value]) PolicyHandler[Key, Value] {
+	return &orPolicy[Key, Value]{policies[Key, Value](ps)}
+}
+
+func (p *orPolicy[Key, Value]) Evict(k Key, v Value, n int) bool {
+	for _, q := range p.policies {
+		if q != nil && q.Evict(k, v, n) {
+			return true
+		}
+	}
+	return false
+}
+
+func (p *orPolicy[Key, Value]) selectFrom(n int, view cacheView[Key, Value]) (k Key, ok bool) {
+	for _, q := range p.policies {
+		if k, ok = selection(q, n, view); ok {
+			return
+		}
+	}
+	return
+}
+
+// attribute returns the index of the first policy which would evict
+// the item with key k. If any policy chooses the items to evict, that
+// is the first policy which would evict anything, as in selectFrom.
+func (p *orPolicy[Key, Value]) attribute(k Key, n int, view cacheView[Key, Value]) int {
+	if p.selects() {
+		for i, q := range p.policies {
+			if _, ok := selection(q, n, view); ok {
+				return i
+			}
+		}
+		return -1
+	}
+	v, _ := view.value(k)
+	for i, q := range p.policies {
+		if q != nil && q.Evict(k, v, n) {
+			return i
+		}
+	}
+	return -1
+}
+
+func (p *orPolicy[Key, Value]) bounded() bool {
+	for _, q := range p.policies {
+		if bounded(q) {
+			return true
+		}
+	}
+	return false
+}
+
+type notPolicy[Key, Value any] struct {
+	policies[Key, Value]
+}
+
+// Not returns a Policy which evicts an item exactly when p would keep
+// it. Combined with And and Or, Not can express a policy which keeps
+// items while a condition holds. Since a nil policy never evicts
+// anything, Not(nil) evicts everything.
+//
+// Not only consults the Evict method of p, so if p implements
+// Selector, Not inverts its decision about the oldest item rather than
+// its choice of item. Like And, the returned value also implements
+// Handler, LookupHandler and PromotionHandler, forwarding events to p
+// if p implements the same interface.
+func Not[Key, Value any](p Policy[Key, Value]) PolicyHandler[Key, Value] {
+	return &notPolicy[Key, Value]{policies[Key, Value]{p}}
+}
+
+func (p *notPolicy[Key, Value]) Evict(k Key, v Value, n int) bool {
+	q := p.policies[0]
+	return q == nil || !q.Evict(k, v, n)
+}
@@ -0,0 +1,772 @@
+// Copyright 2022 The policy-lru Authors. All rights reserved.
+//
+// Use of this source code is governed by the Apache License, Version
+// 2.0 (the "License"); you may not use this file except in compliance
+// with the License. You may find a copy of the license in the file
+// LICENSE or at  http://www.apache.org/licenses/LICENSE-2.0.
+
+package policylru
+
+import (
+	"fmt"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMaxCount(t *testing.T) {
+	t.Run("zero", func(t *testing.T) {
+		var added, removed int
+		lru := NewWithHandler[string, int](MaxCount[string, int](0), &handlerFuncs[string, int]{
+			added:   func(string, int, int, bool) { added++ },
+			removed: func(string, int) { removed++ },
+		})
+
+		lru.Add("a", 1)
+		lru.Add("a", 2)
+		_, ok := lru.Get("a")
+
+		assert.Equal(t, 0, lru.Len())
+		assert.False(t, ok)
+		assert.Equal(t, 0, added)
+		assert.Equal(t, 0, removed)
+	})
+
+	t.Run("negative", func(t *testing.T) {
+		lru := New[string, int](MaxCount[string, int](-1))
+
+		lru.Add("a", 1)
+
+		assert.Equal(t, 0, lru.Len())
+	})
+}
+
+func TestMaxCountVar(t *testing.T) {
+	t.Run("set_max", func(t *testing.T) {
+		p := NewMaxCountVar[string, int](3)
+		lru := New[string, int](p)
+		lru.Add("a", 1)
+		lru.Add("b", 2)
+		lru.Add("c", 3)
+
+		p.SetMax(1)
+		n := lru.Evict()
+		lru.Add("d", 4)
+		count, _ := p.Get("count")
+
+		assert.Equal(t, 1, p.Max())
+		assert.Equal(t, 1, count)
+		assert.Equal(t, 2, n)
+		assert.Equal(t, []string{"d"}, lru.orderedKeysForTest())
+	})
+
+	t.Run("clone_copies_limit", func(t *testing.T) {
+		lru := New[string, int](NewMaxCountVar[string, int](2))
+		clone := lru.Clone()
+
+		err := clone.ConfigurePolicy("count", 1)
+		lru.Add("a", 1)
+		lru.Add("b", 2)
+		clone.Add("a", 1)
+		clone.Add("b", 2)
+
+		assert.NoError(t, err)
+		assert.Equal(t, []string{"b", "a"}, lru.orderedKeysForTest())
+		assert.Equal(t, []string{"b"}, clone.orderedKeysForTest())
+	})
+
+	t.Run("clone_copies_tracked_policy", func(t *testing.T) {
+		p := MaxCountAndSize[string, int](10, 10, func(v int) uint64 { return uint64(v) })
+		lru := NewTracked[string, int](p)
+		lru.Add("a", 4)
+		clone := lru.Clone()
+
+		err := clone.ConfigurePolicy("bytes", 5)
+		clone.Add("b", 4)
+		lru.Add("b", 4)
+
+		assert.NoError(t, err)
+		assert.Same(t, clone.Policy, clone.Handler)
+		assert.NotSame(t, lru.Policy, clone.Policy)
+		assert.Equal(t, []string{"b", "a"}, lru.orderedKeysForTest())
+		assert.Equal(t, []string{"b"}, clone.orderedKeysForTest())
+	})
+}
+
+func TestMaxSize(t *testing.T) {
+	sizeOf := func(v string) uint64 { return uint64(len(v)) }
+	var removed []int
+	p := MaxSize[int, string](5, sizeOf)
+	lru := NewWithHandler[int, string](p, &handlerFuncs[int, string]{
+		added: p.Added,
+		removed: func(k int, v string) {
+			removed = append(removed, k)
+			p.Removed(k, v)
+		},
+	})
+
+	lru.Add(1, "aa")
+	lru.Add(2, "bb")
+	lru.Add(3, "c")
+	assert.Equal(t, 3, lru.Len())
+	lru.Add(4, "dddd")
+
+	assert.Equal(t, []int{1, 2}, removed)
+	assert.Equal(t, []int{4, 3}, lru.orderedKeysForTest())
+
+	err := lru.ConfigurePolicy("bytes", 10)
+	lru.Add(5, "eeeee")
+	bytes, _ := p.(Configurable).Get("bytes")
+
+	assert.NoError(t, err)
+	assert.Equal(t, uint64(10), bytes)
+	assert.Equal(t, []int{5, 4, 3}, lru.orderedKeysForTest())
+	assert.ErrorIs(t, lru.ConfigurePolicy("count", 1), ErrUnknownParameter)
+}
+
+func TestMaxCountAndSize(t *testing.T) {
+	sizeOf := func(v string) uint64 { return uint64(len(v)) }
+
+	t.Run("count_bound", func(t *testing.T) {
+		p := MaxCountAndSize[int, string](2, 100, sizeOf)
+		lru := NewTracked[int, string](p)
+
+		lru.Add(1, "a")
+		lru.Add(2, "b")
+		lru.Add(3, "c")
+		_, ok := lru.Get(1)
+
+		assert.Equal(t, 2, lru.Len())
+		assert.False(t, ok)
+		assert.Equal(t, uint64(2), p.total)
+	})
+
+	t.Run("size_bound", func(t *testing.T) {
+		p := MaxCountAndSize[int, string](10, 5, sizeOf)
+		lru := NewWithHandler[int, string](p, p)
+
+		lru.Add(1, "aaa")
+		lru.Add(2, "bb")
+		assert.Equal(t, 2, lru.Len())
+		lru.Add(1, "aaaa")
+		assert.Equal(t, 2, lru.Len())
+		n := lru.Evict()
+		_, ok := lru.Get(2)
+
+		assert.Equal(t, 1, n)
+		assert.Equal(t, 1, lru.Len())
+		assert.False(t, ok)
+		assert.Equal(t, uint64(4), p.total)
+	})
+}
+
+func TestPartitioned(t *testing.T) {
+	p := Partitioned[string, int](func(k string) byte {
+		return k[0]
+	}, func(part byte) int {
+		if part == 'a' {
+			return 1
+		}
+		return 2
+	})
+	lru := NewTracked[string, int](p)
+
+	evicted := lru.WithEvictionCapture(func() {
+		lru.Add("a1", 1)
+		lru.Add("b1", 1)
+		lru.Add("b2", 2)
+		lru.Add("b3", 3)
+		lru.Get("b2")
+		lru.Add("b4", 4)
+		lru.Add("a2", 2)
+	})
+	lru.Remove("b4")
+	lru.Add("b5", 5)
+
+	assert.Equal(t, []struct {
+		K string
+		V int
+	}{{"b1", 1}, {"b3", 3}, {"a1", 1}}, evicted)
+	assert.Equal(t, 3, lru.Len())
+	assert.Equal(t, []string{"b2", "a2", "b5"}, lru.SurvivingKeys())
+
+	t.Run("drops_empty_partitions", func(t *testing.T) {
+		p := Partitioned[string, int](func(k string) byte {
+			return k[0]
+		}, func(byte) int {
+			return 1
+		})
+		lru := NewTracked[string, int](p)
+		lru.Add("a1", 1)
+		lru.Add("b1", 1)
+		lru.Add("c1", 1)
+
+		lru.Remove("a1")
+		lru.Remove("c1")
+		lru.Add("c2", 2)
+		lru.Add("c3", 3)
+
+		assert.Equal(t, []byte{'b', 'c'}, p.order)
+		assert.Len(t, p.parts, 2)
+		assert.Equal(t, []string{"c3", "b1"}, lru.orderedKeysForTest())
+	})
+}
+
+func TestAdaptive(t *testing.T) {
+	t.Run("adapts", func(t *testing.T) {
+		p := Adaptive[string, int](2, 3)
+		p.Window = 4
+		lru := NewTracked[string, int](p)
+		lru.Add("a", 1)
+		lru.Add("b", 2)
+
+		for i := 0; i < 4; i++ {
+			lru.Get("a")
+		}
+		lru.Add("c", 3)
+
+		assert.Equal(t, 3, p.Limit())
+		assert.Equal(t, 3, lru.Len())
+
+		for i := 0; i < 8; i++ {
+			lru.Get("a")
+		}
+
+		assert.Equal(t, 3, p.Limit())
+
+		for i := 0; i < 8; i++ {
+			lru.Get("x")
+		}
+		n := lru.Evict()
+
+		assert.Equal(t, 2, p.Limit())
+		assert.Equal(t, 1, n)
+		assert.Equal(t, 2, lru.Len())
+	})
+
+	t.Run("not_full", func(t *testing.T) {
+		p := Adaptive[string, int](2, 10)
+		p.Window = 2
+		lru := NewTracked[string, int](p)
+		lru.Add("a", 1)
+
+		lru.Get("a")
+		lru.Get("a")
+
+		assert.Equal(t, 2, p.Limit())
+	})
+
+	t.Run("panics", func(t *testing.T) {
+		assert.Panics(t, func() { Adaptive[string, int](0, 1) })
+		assert.Panics(t, func() { Adaptive[string, int](2, 1) })
+	})
+}
+
+func TestEvictPreferring(t *testing.T) {
+	t.Run("prefers", func(t *testing.T) {
+		flushed := func(_ string, v int) bool { return v < 0 }
+		lru := New[string, int](EvictPreferring[string, int](flushed, MaxCount[string, int](3)))
+		lru.Add("a", 1)
+		lru.Add("b", -2)
+		lru.Add("c", -3)
+
+		lru.Add("d", 4)
+
+		assert.Equal(t, []string{"d", "c", "a"}, lru.orderedKeysForTest())
+
+		lru.Add("e", 5)
+
+		assert.Equal(t, []string{"e", "d", "a"}, lru.orderedKeysForTest())
+
+		lru.Add("f", 6)
+
+		assert.Equal(t, []string{"f", "e", "d"}, lru.orderedKeysForTest())
+	})
+
+	t.Run("boosted", func(t *testing.T) {
+		lru := New[string, int](EvictPreferring[string, int](func(k string, _ int) bool {
+			return k == "b"
+		}, MaxCount[string, int](2)))
+		lru.Add("a", 1)
+		lru.Add("b", 2)
+		lru.Boost("b", 1)
+
+		lru.Add("c", 3)
+
+		assert.Equal(t, []string{"c", "b"}, lru.orderedKeysForTest())
+	})
+
+	t.Run("tracked", func(t *testing.T) {
+		p := MaxCountAndSize[string, int](10, 10, func(v int) uint64 { return uint64(v) })
+		lru := NewTracked[string, int](EvictPreferring[string, int](func(k string, _ int) bool {
+			return k == "b"
+		}, p))
+		lru.Add("a", 4)
+		lru.Add("b", 4)
+		lru.Add("c", 4)
+
+		assert.Equal(t, []string{"c", "a"}, lru.orderedKeysForTest())
+		assert.Equal(t, uint64(8), p.total)
+	})
+
+	t.Run("lfu", func(t *testing.T) {
+		lru := NewTracked[string, int](EvictPreferring[string, int](func(string, int) bool {
+			return false
+		}, LFU[string, int](2)))
+
+		lru.Add("a", 1)
+		lru.Add("b", 2)
+		lru.Get("a")
+		lru.Get("b")
+		lru.Get("a")
+		lru.Get("b")
+		lru.Get("a")
+		lru.Add("c", 3)
+
+		assert.Equal(t, []string{"c", "a"}, lru.orderedKeysForTest())
+	})
+
+	t.Run("expire_after", func(t *testing.T) {
+		now := time.Unix(1000, 0)
+		expire := ExpireAfter[string, int](time.Minute)
+		expire.Clock = func() time.Time { return now }
+		lru := NewTracked[string, int](EvictPreferring[string, int](func(string, int) bool {
+			return false
+		}, expire))
+
+		lru.Add("old", 1)
+		now = now.Add(30 * time.Second)
+		lru.Add("young", 2)
+		lru.Get("old")
+		now = now.Add(30 * time.Second)
+		n := lru.Evict()
+
+		assert.Equal(t, 1, n)
+		assert.Equal(t, []string{"young"}, lru.orderedKeysForTest())
+	})
+
+	t.Run("forwards_events", func(t *testing.T) {
+		var events []string
+		lru := NewTracked[string, int](EvictPreferring[string, int](func(string, int) bool {
+			return false
+		}, &eventPolicyForTest{events: &events}))
+
+		lru.Add("a", 1)
+		lru.Add("b", 2)
+		lru.Get("a")
+		lru.Get("x")
+
+		assert.Equal(t, []string{"promoted a", "lookup a true", "lookup x false"}, events)
+	})
+}
+
+func TestMaxCountMinRetain(t *testing.T) {
+	now := time.Unix(1000, 0)
+	p := MaxCountMinRetain[string, int](2, time.Minute)
+	p.Clock = func() time.Time { return now }
+	lru := NewTracked[string, int](p)
+
+	lru.Add("a", 1)
+	now = now.Add(time.Minute)
+	lru.Add("b", 2)
+	lru.Add("c", 3)
+	lru.Add("d", 4)
+
+	assert.Equal(t, []string{"d", "c", "b"}, lru.orderedKeysForTest())
+	assert.Equal(t, []string{"b", "c", "d"}, lru.SurvivingKeys())
+
+	lru.Get("b")
+	lru.Add("b", 20)
+	now = now.Add(30 * time.Second)
+	lru.Evict()
+
+	assert.Equal(t, 3, lru.Len())
+
+	now = now.Add(30 * time.Second)
+	n := lru.Evict()
+
+	assert.Equal(t, 1, n)
+	assert.Equal(t, []string{"b", "d"}, lru.orderedKeysForTest())
+}
+
+func TestExpireAfter(t *testing.T) {
+	now := time.Unix(1000, 0)
+	p := ExpireAfter[string, int](time.Minute)
+	p.Clock = func() time.Time { return now }
+	lru := NewTracked[string, int](p)
+
+	lru.Add("a", 1)
+	now = now.Add(30 * time.Second)
+	lru.Add("b", 2)
+	lru.Add("c", 3)
+	lru.Get("a")
+	n1 := lru.Evict()
+
+	assert.Equal(t, 0, n1)
+	assert.Equal(t, 3, lru.Len())
+
+	now = now.Add(30 * time.Second)
+	lru.Add("b", 20)
+	n2 := lru.Evict()
+
+	assert.Equal(t, 1, n2)
+	assert.Equal(t, []string{"b", "c"}, lru.orderedKeysForTest())
+
+	now = now.Add(30 * time.Second)
+	lru.Add("d", 4)
+
+	assert.Equal(t, []string{"d", "b"}, lru.orderedKeysForTest())
+	assert.False(t, p.Evict("b", 20, 2))
+
+	now = now.Add(30 * time.Second)
+
+	assert.True(t, p.Evict("b", 20, 2))
+}
+
+func TestLFU(t *testing.T) {
+	t.Run("least_frequent", func(t *testing.T) {
+		p := LFU[string, int](2)
+		lru := NewTracked[string, int](p)
+
+		lru.Add("a", 1)
+		lru.Add("b", 2)
+		lru.Get("a")
+		lru.Get("a")
+		lru.Get("b")
+		lru.Add("c", 3)
+
+		assert.Equal(t, []string{"c", "a"}, lru.orderedKeysForTest())
+
+		lru.Get("c")
+		lru.Get("c")
+		lru.Get("c")
+		lru.Add("d", 4)
+
+		assert.Equal(t, []string{"d", "c"}, lru.orderedKeysForTest())
+
+		lru.Get("a")
+		lru.Add("e", 5)
+
+		assert.Equal(t, []string{"e", "c"}, lru.orderedKeysForTest())
+	})
+	t.Run("ties_least_recent", func(t *testing.T) {
+		p := LFU[string, int](2)
+		lru := NewTracked[string, int](p)
+
+		lru.Add("a", 1)
+		lru.Add("b", 2)
+		lru.Get("b")
+		lru.Get("a")
+		lru.Add("c", 3)
+		_, okB := lru.Get("b")
+
+		assert.False(t, okB)
+		assert.Equal(t, []string{"c", "a"}, lru.orderedKeysForTest())
+	})
+	t.Run("removed_forgotten", func(t *testing.T) {
+		p := LFU[string, int](3)
+		lru := NewTracked[string, int](p)
+
+		lru.Add("a", 1)
+		lru.Get("a")
+		lru.Remove("a")
+		lru.Clear()
+
+		assert.Empty(t, p.elems)
+		assert.Empty(t, p.freqs)
+	})
+}
+
+func TestAnd(t *testing.T) {
+	sizeOf := func(v string) uint64 { return uint64(len(v)) }
+
+	t.Run("all_must_agree", func(t *testing.T) {
+		lru := NewTracked[int, string](And[int, string](
+			MaxCount[int, string](2),
+			MaxSize[int, string](4, sizeOf),
+		))
+
+		lru.Add(1, "a")
+		lru.Add(2, "b")
+		lru.Add(3, "c")
+		assert.Equal(t, []int{3, 2, 1}, lru.orderedKeysForTest())
+		lru.Add(4, "dd")
+
+		assert.Equal(t, []int{4, 3, 2}, lru.orderedKeysForTest())
+	})
+	t.Run("attribution", func(t *testing.T) {
+		p := And[int, string](
+			MaxCount[int, string](2),
+			MaxSize[int, string](4, sizeOf),
+		)
+		var evicting []string
+		lru := NewWithHandler[int, string](p, attributingForTest[int, string]{p, &evicting})
+
+		lru.Add(1, "a")
+		lru.Add(2, "b")
+		lru.Add(3, "c")
+		lru.Add(4, "dd")
+
+		assert.Equal(t, []string{"1 by 0"}, evicting)
+	})
+	t.Run("not_composite", func(t *testing.T) {
+		p := MaxSize[int, string](2, sizeOf)
+		var evicting []string
+		lru := NewWithHandler[int, string](p, attributingForTest[int, string]{p, &evicting})
+
+		lru.Add(1, "a")
+		lru.Add(2, "b")
+		lru.Add(3, "c")
+
+		assert.Equal(t, []string{"1 by -1"}, evicting)
+	})
+	t.Run("lfu", func(t *testing.T) {
+		lru := NewTracked[string, int](And[string, int](
+			LFU[string, int](2),
+			Not[string, int](PolicyFunc[string, int](func(k string, _ int, _ int) bool {
+				return k == "pinned"
+			})),
+		))
+
+		lru.Add("a", 1)
+		lru.Add("b", 2)
+		lru.Get("a")
+		lru.Get("a")
+		lru.Get("b")
+		lru.Add("c", 3)
+
+		assert.Equal(t, []string{"c", "a"}, lru.orderedKeysForTest())
+
+		lru.Add("pinned", 4)
+
+		assert.Equal(t, []string{"pinned", "a"}, lru.orderedKeysForTest())
+
+		lru.Add("d", 5)
+
+		assert.Equal(t, []string{"d", "pinned", "a"}, lru.orderedKeysForTest())
+	})
+	t.Run("expire_after", func(t *testing.T) {
+		now := time.Unix(1000, 0)
+		expire := ExpireAfter[string, int](time.Minute)
+		expire.Clock = func() time.Time { return now }
+		lru := NewTracked[string, int](And[string, int](expire, MaxCount[string, int](1)))
+
+		lru.Add("old", 1)
+		now = now.Add(30 * time.Second)
+		lru.Add("young", 2)
+		lru.Get("old")
+		now = now.Add(30 * time.Second)
+		n := lru.Evict()
+
+		assert.Equal(t, 1, n)
+		assert.Equal(t, []string{"young"}, lru.orderedKeysForTest())
+	})
+	t.Run("degenerate", func(t *testing.T) {
+		assert.False(t, And[int, string]().Evict(1, "a", 100))
+		assert.False(t, And[int, string](MaxCount[int, string](0), nil).Evict(1, "a", 100))
+		assert.False(t, New[int, string](And[int, string]()).Bounded())
+		assert.False(t, New[int, string](And[int, string](MaxCount[int, string](1), nil)).Bounded())
+		assert.True(t, New[int, string](And[int, string](MaxCount[int, string](1))).Bounded())
+	})
+}
+
+func TestOr(t *testing.T) {
+	sizeOf := func(v string) uint64 { return uint64(len(v)) }
+
+	t.Run("any_may_evict", func(t *testing.T) {
+		var removed []int
+		lru := NewTracked[int, string](Or[int, string](
+			MaxCount[int, string](3),
+			MaxSize[int, string](4, sizeOf),
+			removedPolicyForTest[int, string]{func(k int, _ string) {
+				removed = append(removed, k)
+			}},
+		))
+
+		lru.Add(1, "a")
+		lru.Add(2, "b")
+		lru.Add(3, "c")
+		lru.Add(4, "d")
+		assert.Equal(t, []int{4, 3, 2}, lru.orderedKeysForTest())
+		lru.Add(5, "eee")
+
+		assert.Equal(t, []int{5, 4}, lru.orderedKeysForTest())
+		assert.Equal(t, []int{1, 2, 3}, removed)
+	})
+	t.Run("requery_after_each_removal", func(t *testing.T) {
+		var calls []int
+		limit := 10
+		lru := New[int, string](Or[int, string](
+			PolicyFunc[int, string](func(_ int, _ string, n int) bool {
+				calls = append(calls, n)
+				return n > limit
+			}),
+		))
+		for i := 0; i < 5; i++ {
+			lru.Add(i, "x")
+		}
+		calls = nil
+		limit = 2
+
+		n := lru.Evict()
+
+		assert.Equal(t, 3, n)
+		assert.Equal(t, []int{5, 4, 3, 2}, calls)
+		assert.Equal(t, []int{4, 3}, lru.orderedKeysForTest())
+	})
+	t.Run("expire_after", func(t *testing.T) {
+		now := time.Unix(1000, 0)
+		expire := ExpireAfter[string, int](time.Minute)
+		expire.Clock = func() time.Time { return now }
+		lru := NewTracked[string, int](Or[string, int](MaxCount[string, int](100), expire))
+
+		lru.Add("old", 1)
+		now = now.Add(30 * time.Second)
+		lru.Add("young", 2)
+		lru.Get("old")
+		now = now.Add(30 * time.Second)
+		n := lru.Evict()
+
+		assert.Equal(t, 1, n)
+		assert.Equal(t, []string{"young"}, lru.orderedKeysForTest())
+	})
+	t.Run("lfu", func(t *testing.T) {
+		lru := NewTracked[string, int](Or[string, int](LFU[string, int](2)))
+
+		lru.Add("a", 1)
+		lru.Add("b", 2)
+		lru.Get("a")
+		lru.Get("a")
+		lru.Get("b")
+		lru.Get("a")
+		lru.Get("b")
+		lru.Add("c", 3)
+
+		assert.Equal(t, []string{"c", "a"}, lru.orderedKeysForTest())
+	})
+	t.Run("forwards_events", func(t *testing.T) {
+		var events []string
+		lru := NewTracked[string, int](Or[string, int](&eventPolicyForTest{events: &events}))
+
+		lru.Add("a", 1)
+		lru.Add("b", 2)
+		lru.Get("a")
+		lru.Get("x")
+
+		assert.Equal(t, []string{"promoted a", "lookup a true", "lookup x false"}, events)
+	})
+	t.Run("attribution", func(t *testing.T) {
+		p := Or[int, string](
+			MaxCount[int, string](3),
+			MaxSize[int, string](4, sizeOf),
+		)
+		var evicting []string
+		lru := NewWithHandler[int, string](p, attributingForTest[int, string]{p, &evicting})
+
+		lru.Add(1, "a")
+		lru.Add(2, "b")
+		lru.Add(3, "c")
+		lru.Add(4, "d")
+		lru.Add(5, "eee")
+
+		assert.Equal(t, []string{"1 by 0", "2 by 0", "3 by 1"}, evicting)
+		assert.Equal(t, []int{5, 4}, lru.orderedKeysForTest())
+	})
+	t.Run("attribution_selector", func(t *testing.T) {
+		now := time.Unix(1000, 0)
+		ttl := ExpireAfter[string, int](time.Minute)
+		ttl.Clock = func() time.Time { return now }
+		p := Or[string, int](MaxCount[string, int](2), ttl)
+		var evicting []string
+		lru := NewWithHandler[string, int](p, attributingForTest[string, int]{p, &evicting})
+
+		lru.Add("a", 1)
+		lru.Add("b", 2)
+		lru.Add("c", 3)
+		now = now.Add(time.Minute)
+		lru.Get("b")
+		lru.Evict()
+
+		assert.Equal(t, []string{"a by 0", "b by 1", "c by 1"}, evicting)
+	})
+	t.Run("degenerate", func(t *testing.T) {
+		assert.False(t, Or[int, string]().Evict(1, "a", 100))
+		assert.True(t, Or[int, string](nil, MaxCount[int, string](0)).Evict(1, "a", 100))
+		assert.False(t, New[int, string](Or[int, string]()).Bounded())
+		assert.False(t, New[int, string](Or[int, string](nil)).Bounded())
+		assert.True(t, New[int, string](Or[int, string](nil, MaxCount[int, string](1))).Bounded())
+	})
+}
+
+// attributingForTest forwards Handler events to a policy, and records
+// the attributed evictions.
+type attributingForTest[Key, Value any] struct {
+	PolicyHandler[Key, Value]
+	evicting *[]string
+}
+
+func (a attributingForTest[Key, Value]) Evicting(k Key, policy int) {
+	*a.evicting = append(*a.evicting, fmt.Sprintf("%v by %d", k, policy))
+}
+
+// removedPolicyForTest is a Policy which never evicts anything, and
+// is also a Handler which records removals.
+type removedPolicyForTest[Key, Value any] struct {
+	RemovedFunc[Key, Value]
+}
+
+func (removedPolicyForTest[Key, Value]) Evict(Key, Value, int) bool {
+	return false
+}
+
+func TestNot(t *testing.T) {
+	p := MaxCount[int, string](2)
+	not := Not[int, string](p)
+
+	assert.True(t, p.Evict(1, "a", 3))
+	assert.False(t, not.Evict(1, "a", 3))
+	assert.False(t, p.Evict(1, "a", 2))
+	assert.True(t, not.Evict(1, "a", 2))
+	assert.True(t, Not[int, string](nil).Evict(1, "a", 0))
+
+	t.Run("forwards_events", func(t *testing.T) {
+		var events []string
+		lru := NewTracked[string, int](Not[string, int](&eventPolicyForTest{evict: true, events: &events}))
+
+		lru.Add("a", 1)
+		lru.Add("b", 2)
+		lru.Get("a")
+		lru.Get("x")
+
+		assert.Equal(t, []string{"a", "b"}, lru.orderedKeysForTest())
+		assert.Equal(t, []string{"promoted a", "lookup a true", "lookup x false"}, events)
+	})
+}
+
+// eventPolicyForTest is a Policy which evicts either everything or
+// nothing, and is also a LookupHandler and PromotionHandler which
+// records the events it receives.
+type eventPolicyForTest struct {
+	evict  bool
+	events *[]string
+}
+
+func (p *eventPolicyForTest) Evict(string, int, int) bool {
+	return p.evict
+}
+
+func (p *eventPolicyForTest) Added(string, int, int, bool) {
+}
+
+func (p *eventPolicyForTest) Removed(string, int) {
+}
+
+func (p *eventPolicyForTest) LookedUp(k string, hit bool) {
+	*p.events = append(*p.events, "lookup "+k+" "+strconv.FormatBool(hit))
+}
+
+func (p *eventPolicyForTest) Promoted(k string, _, _ int) {
+	*p.events = append(*p.events, "promoted "+k)
+}
@@ -16,6 +16,9 @@ package policylru
 
 import (
 	"container/list"
+	"errors"
+	"time"
+	"unsafe"
 )
 
 // Policy is a cache eviction policy.
@@ -41,26 +44,440 @@ type Handler[Key, Value any] interface {
 	Removed(k Key, v Value)
 }
 
+// PolicyHandler is a Policy which is also a Handler. Stateful
+// policies, such as ones which limit the total size of the values in
+// the cache, typically use Handler events to maintain their state and
+// so implement PolicyHandler.
+type PolicyHandler[Key, Value any] interface {
+	Policy[Key, Value]
+	Handler[Key, Value]
+}
+
+// PromotionHandler is an optional extension to Handler. If a Cache's
+// Handler also implements PromotionHandler, it is notified each time
+// Get promotes an entry to the front of the cache's recency list.
+type PromotionHandler[Key any] interface {
+	// Promoted is called after Get moves an element from position
+	// fromRank in the recency list to position toRank, where rank 0 is
+	// the most recently used element. Promoted is not called if the
+	// element was already the most recently used one.
+	//
+	// Finding the rank of an element takes time proportional to the
+	// rank, so fromRank is only computed if the Cache's RankPromotions
+	// field is true. Otherwise fromRank is -1.
+	Promoted(k Key, fromRank, toRank int)
+}
+
+// LookupHandler is an optional extension to Handler. If a Cache's
+// Handler also implements LookupHandler, it is notified of every
+// lookup made by Get and its variants, whether it hits or misses. This
+// allows, for example, a policy to adapt to the cache's hit ratio.
+// A lookup which misses the cache but is served by its Fallback is
+// reported as a miss.
+type LookupHandler[Key any] interface {
+	// LookedUp is called after a lookup of the key k, with hit
+	// reporting whether k was found in the cache.
+	LookedUp(k Key, hit bool)
+}
+
+// EvictionHandler is an optional extension to Handler. If a Cache's
+// Handler also implements EvictionHandler, it is told of each item the
+// eviction policy decides to evict, and, if the policy was built by And
+// or Or, which of the combined policies decided. This shows, for
+// example, whether a count or a size limit is the binding constraint.
+//
+// Since the policy's own Handler events must still reach it, install
+// the policy with NewWithHandler and a Handler which forwards them:
+//
+//	type attributing struct {
+//		policylru.PolicyHandler[string, []byte]
+//	}
+//
+//	func (a attributing) Evicting(k string, policy int) {
+//		evictions[policy]++
+//	}
+//
+//	p := policylru.Or[string, []byte](
+//		policylru.MaxCount[string, []byte](1000),
+//		policylru.MaxSize[string, []byte](1<<20, sizeOf),
+//	)
+//	lru := policylru.NewWithHandler[string, []byte](p, attributing{p})
+type EvictionHandler[Key any] interface {
+	// Evicting is called when the eviction policy decides to evict the
+	// item with key k, before the item is removed and its Removed event
+	// is generated, or before it becomes a pending eviction if the
+	// Cache's TwoPhaseEviction field is set.
+	//
+	// If the Cache's Policy was returned by Or, policy is the index,
+	// among the arguments to Or, of the first policy which would evict
+	// the item. If it was returned by And, where every policy agrees to
+	// the eviction, policy is zero, the index of the first policy, which
+	// chose the item. For any other Policy, policy is -1.
+	Evicting(k Key, policy int)
+}
+
+// Selector is an optional extension to Policy for policies which choose
+// the items to evict themselves, rather than deciding whether to evict
+// the oldest item. If a Cache's Policy implements Selector, Evict calls
+// Select instead of Evict to find each item to remove.
+//
+// A Selector typically needs to know which keys are in the cache, and
+// their recency, to make its choice. It can track them by implementing
+// Handler and PromotionHandler, and being installed as the Cache's
+// Handler as well as its Policy.
+type Selector[Key any] interface {
+	// Select returns the key of the next item to evict from a cache
+	// containing n items, or false if nothing more should be evicted.
+	//
+	// When Evict calls Select and gets a key which is in the cache,
+	// the item with that key is removed from the cache. If Select
+	// returns a key which is not in the cache, Evict stops. Select may
+	// also be called to check whether the cache is over its limit
+	// without evicting anything, so it must not change the policy's
+	// state.
+	Select(n int) (k Key, ok bool)
+}
+
+// Preferrer is an optional extension to Policy for policies which
+// prefer to evict certain items before others, regardless of recency.
+// If a Cache's Policy implements Preferrer, each time the policy
+// decides to evict the oldest item, Evict instead removes the least
+// recently used item for which Prefer returns true, if there is one,
+// and otherwise the oldest item.
+//
+// Finding a preferred item takes time proportional to the number of
+// items in the cache, since every item may have to be checked.
+type Preferrer[Key, Value any] interface {
+	// Prefer reports whether the item should be evicted before items
+	// for which Prefer returns false.
+	Prefer(k Key, v Value) bool
+}
+
+// Retainer is an optional extension to Policy for policies which need
+// to keep certain items even when the cache is over its limit. If a
+// Cache's Policy implements Retainer, and the policy decides to evict
+// an item for which Retain returns true, the item survives and Evict
+// moves on to consider the next oldest item, as it does for an item
+// protected by Boost. The cache may therefore stay over its limit for
+// as long as the policy retains the items which would bring it back
+// under.
+//
+// Retainer has no effect on a policy which also implements Selector.
+type Retainer[Key, Value any] interface {
+	// Retain reports whether the item must not be evicted now.
+	Retain(k Key, v Value) bool
+}
+
+// OverflowPolicy determines what a Cache does when a new key is added
+// and the eviction policy decides that the cache is over its limit.
+type OverflowPolicy int
+
+const (
+	// OverflowEvict makes room for the new key by evicting items as
+	// directed by the eviction policy. This is the default.
+	OverflowEvict OverflowPolicy = iota
+	// OverflowReject silently rejects the new key, leaving the cache
+	// as it was.
+	OverflowReject
+	// OverflowError rejects the new key, leaving the cache as it was,
+	// and makes AddOrErr return ErrCacheFull.
+	OverflowError
+)
+
+// ErrCacheFull is returned by AddOrErr when a new key is rejected
+// because the cache is full and its Overflow field is OverflowError.
+var ErrCacheFull = errors.New("policylru: cache is full")
+
+// ErrValueTooLarge is returned by AddOrErr when a value is rejected
+// because its size exceeds the cache's MaxValueSize.
+var ErrValueTooLarge = errors.New("policylru: value too large")
+
 // Cache is a Policy-driven LRU cache. It is not safe for concurrent
 // access.
 //
 // The API for Cache is essentially identical to the one defined by
 // lru.Cache in https://github.com/golang/groupcache, so Cache is usable
 // as a drop-in replacement for lru.Cache.
+//
+// Cache is deterministic: given the same sequence of operations and a
+// policy which does not depend on the time or other external state, it
+// evicts the same items and generates the same Handler events in the
+// same order. Methods which pass every item to a callback, such as
+// GroupBy and EstimatedBytes, visit the items in recency order, least
+// recently used first. Only the iteration order of the maps returned by
+// methods such as ToMap and KeySet is unspecified, as for any map.
 type Cache[Key comparable, Value any] struct {
 	// Policy is the cache eviction policy. If Policy is nil, no element
 	// will ever be evicted from the cache.
 	Policy Policy[Key, Value]
 	// Handler is the optional cache eviction handler.
 	Handler Handler[Key, Value]
+	// RankPromotions enables computation of the fromRank argument
+	// passed to a Handler that implements PromotionHandler.
+	RankPromotions bool
+	// KeyNormalizer is an optional function applied to every key passed
+	// to a key-based method such as Add, Get or Remove before the key
+	// is used. Keys which normalize to the same value refer to the same
+	// entry. For example, setting KeyNormalizer to strings.ToLower makes
+	// a Cache with string keys case-insensitive.
+	//
+	// The cache stores the normalized form of each key, so the keys
+	// passed to the Policy and Handler are normalized keys.
+	KeyNormalizer func(Key) Key
+	// MaxDistinctKeys, if positive, limits the number of distinct keys
+	// the cache will ever admit, as a defense against unbounded key
+	// spaces supplied by untrusted input. Once MaxDistinctKeys distinct
+	// keys have been added, Add silently ignores keys it has not seen
+	// before, even if the entries for the keys already seen have since
+	// been removed. Adding a key which has been seen before is always
+	// allowed.
+	//
+	// Use ResetDistinctKeys to forget the keys seen so far.
+	MaxDistinctKeys int
+	// DisableLazyInit makes Add panic, rather than silently initialize
+	// the cache, if it is called on a Cache whose internal storage has
+	// not been initialized, which is the case for the zero value of
+	// Cache.
+	//
+	// By default, the zero value of Cache is ready to use. Setting
+	// DisableLazyInit helps find logic errors where a Cache is used
+	// without having been created by New or one of its variants.
+	DisableLazyInit bool
+	// ReverseEvictionEvents changes the order of the Removed events the
+	// Handler receives when a single call to Evict, including the
+	// implicit call made by Add, removes several items. By default,
+	// each Removed event is generated as soon as its item is removed,
+	// so events are received oldest item first. If
+	// ReverseEvictionEvents is true, the events are held until all the
+	// items have been removed and are then generated newest item first.
+	//
+	// Because the events are held back, a Policy which relies on
+	// Removed events to track its state, such as a policy which limits
+	// the total size of the cache, will not see its state change during
+	// the eviction and may evict too much. Do not set
+	// ReverseEvictionEvents when using such a policy.
+	ReverseEvictionEvents bool
+	// Bloom is an optional BloomFilter which records every key added to
+	// the cache. If Bloom is not nil, Get and DefinitelyAbsent consult
+	// it first, so that lookups of keys which were never added to the
+	// cache can be answered cheaply.
+	//
+	// The filter only records keys added after it is installed, so it
+	// should be installed while the cache is empty.
+	Bloom *BloomFilter[Key]
+	// DefaultValue optionally supplies the value returned by
+	// GetOrDefault for a key which is not in the cache.
+	DefaultValue func(k Key) Value
+	// CopyOnGet optionally copies values on their way out of the cache.
+	// If CopyOnGet is not nil, Get and the other lookup methods return
+	// CopyOnGet(v) instead of the stored value v. This protects the
+	// cache from callers who modify a value which shares memory, such
+	// as a slice or map, with the stored value. The methods which hand
+	// out many stored values at once, such as Range, All, ToMap, Values,
+	// Newest and PendingEvictions, copy each value likewise.
+	//
+	// The exceptions are the methods which only pass values to a
+	// function for inspection, namely GroupBy, GroupKeysBy,
+	// EstimatedBytes and ExportBytes, and the Handler's events, which
+	// receive the stored values without copying, so that classifying,
+	// measuring or encoding the cache does not copy every value in it.
+	// These functions must not modify the values they are given.
+	//
+	// CopyOnGet is called on every cache hit, so copying large values
+	// can make lookups much more expensive.
+	CopyOnGet func(Value) Value
+	// CopyOnAdd optionally copies values on their way into the cache.
+	// If CopyOnAdd is not nil, Add and the other methods which store
+	// values store CopyOnAdd(v) instead of the value v they are given,
+	// so that later changes the caller makes to v do not affect the
+	// cache. The Handler receives the copied value.
+	//
+	// Use CopyOnAdd together with CopyOnGet to fully isolate mutable
+	// values stored in the cache from its callers.
+	CopyOnAdd func(Value) Value
+	// Overflow determines what happens when adding a new key would
+	// require items to be evicted. By default it is OverflowEvict,
+	// which evicts them. If it is OverflowReject or OverflowError, the
+	// new key is rejected instead. Updating the value of a key which is
+	// already in the cache always succeeds.
+	//
+	// Whether the new key fits is decided before it is added, by asking
+	// the eviction policy about the cache as it would be with one more
+	// item, so a rejected key leaves no trace: it generates no Handler
+	// events and is not counted by the statistics, the Bloom filter or
+	// MaxDistinctKeys. A Selector is asked to Select from a cache of one
+	// more item, without having seen the new key, and a policy which
+	// is not one of the built-in ones is asked to Evict the oldest item
+	// with the count raised by one.
+	Overflow OverflowPolicy
+	// Validator optionally checks values on their way out of the cache.
+	// If Validator is not nil, Get and the other lookup methods call it
+	// on every hit, and if it returns false, the entry is removed from
+	// the cache, generating a Removed event, and the lookup reports a
+	// miss. This lets a cache heal itself of values which have become
+	// invalid, for example because they were corrupted, by recomputing
+	// them on the next miss.
+	//
+	// Validator runs on every cache hit, so it should be cheap.
+	Validator func(k Key, v Value) bool
+	// ZeroOnRemove optionally scrubs values as they leave the cache, to
+	// shorten the time sensitive values such as secrets stay in memory.
+	// If ZeroOnRemove is not nil, it is called with a pointer to the
+	// cache's copy of each value removed from the cache, for whatever
+	// reason, after the Handler has received the Removed event for it.
+	// For example, for []byte values, ZeroOnRemove could overwrite the
+	// bytes with zeros and set the value to nil.
+	//
+	// Only the cache's copy of the value is scrubbed. Copies held by
+	// callers, including values returned by Get, RemoveOldest and
+	// WithEvictionCapture, are not, although they may share memory, such as the bytes of a
+	// slice, with the scrubbed value.
+	ZeroOnRemove func(v *Value)
+	// TrackInsertionOrder enables RangeInsertionOrder by keeping a
+	// second list of the entries in the order their keys were first
+	// added. The list only records entries added after it is enabled,
+	// so TrackInsertionOrder should be set while the cache is empty.
+	TrackInsertionOrder bool
+	// OnEmpty is an optional function called whenever the cache becomes
+	// empty because its last item was removed, whether by the eviction
+	// policy, Remove, Clear or any other means. It is called after the
+	// removal is complete, including any Removed events and removals
+	// caused by dependencies, and only if the cache is still empty at
+	// that point. It is not called for a cache which was already empty.
+	OnEmpty func()
+	// MaxValueSize, if positive, limits the size of each value which
+	// may be stored in the cache, as measured by ValueSize. This keeps
+	// a single huge value from dominating the cache. It is unlike a
+	// policy limiting the total size of the cache, since an oversized
+	// value is never stored, so nothing is evicted to make room for it.
+	//
+	// Add and its variants silently ignore a value whose size exceeds
+	// MaxValueSize, and AddOrErr returns ErrValueTooLarge for it. If
+	// the key is already in the cache, the update is refused and the
+	// key keeps its current value. MaxValueSize has no effect if
+	// ValueSize is nil.
+	MaxValueSize int64
+	// ValueSize measures the values checked against MaxValueSize.
+	ValueSize func(Value) int64
+	// Fallback is an optional next-level cache, making the Cache the
+	// first level of a cache hierarchy. If Fallback is not nil, Get and
+	// GetWithStale look up a key which is not in the cache in Fallback,
+	// and if it is found there, add its value to the cache, as if by
+	// Add, before returning it. A value found in Fallback is reported
+	// as a hit by Get.
+	//
+	// The statistics returned by Stats and the events received by a
+	// LookupHandler describe this level of the hierarchy only, so a
+	// lookup served by Fallback is counted as a miss, and the handler's
+	// LookedUp method is called with hit false, even though Get reports
+	// the value as found. This keeps the hit ratio a measure of how
+	// well the Cache itself is working.
+	//
+	// The Cacher interface does not report errors, so a failure of the
+	// next level can only appear as a miss. Fallback is not consulted
+	// by other methods, and values are never written through to it.
+	Fallback Cacher[Key, Value]
+	// RecordAccessTimes enables AccessInfo by recording, for each item
+	// added while it is set, the time the item was added and the time
+	// it was last accessed by Get, one of its variants, or an update.
+	// Recording the times costs a clock reading on every access and
+	// some memory per item, so it is off by default.
+	RecordAccessTimes bool
+	// Clock optionally supplies the current time for the time-based
+	// features of the cache, such as the deadlines set by AddUntil and
+	// the times recorded by RecordAccessTimes. If Clock is nil,
+	// time.Now is used. Tests can set Clock to control time.
+	Clock func() time.Time
+	// TwoPhaseEviction changes what happens to the items the eviction
+	// policy decides to evict. Rather than being removed at once, they
+	// become pending evictions, which gives consumers of the cache a
+	// window in which to react before the items disappear. Pending
+	// items are removed, generating Removed events as usual, when
+	// CommitEvictions is called, or automatically once EvictionGrace
+	// further calls to Evict have been made, including the implicit
+	// calls made by Add.
+	//
+	// Until it is removed, a pending item stays in the cache: it is
+	// counted by Len, and Get and the other lookup methods find it. An
+	// item which is looked up by Get, promoted by TouchAll or updated
+	// by Add is rescued: it is no longer pending and becomes the most
+	// recently used item. The eviction policy does not see pending
+	// items, and the item count it is given excludes them, so the
+	// policy keeps the number of items which are not pending within
+	// its limit. Use PendingEvictions to see the pending items.
+	//
+	// Because pending items generate no Removed events until they are
+	// removed, a policy which tracks the cache using Handler events,
+	// such as MaxCountAndSize, does not see them leave, and would go on
+	// to make every item pending. Only use TwoPhaseEviction with
+	// policies which decide based on the item and the item count, such
+	// as MaxCount. If the policy implements Selector, an Evict call
+	// stops when the policy selects an item which is already pending.
+	//
+	// Clearing TwoPhaseEviction does not remove the items already
+	// pending. Call CommitEvictions to remove them.
+	TwoPhaseEviction bool
+	// EvictionGrace is the number of calls to Evict for which an item
+	// remains pending when TwoPhaseEviction is set. An item which
+	// becomes pending during one call to Evict is removed at the start
+	// of the EvictionGrace-th call after it. If EvictionGrace is zero
+	// or negative, pending items are only removed by CommitEvictions.
+	EvictionGrace int
+
+	ll           *list.List
+	insertion    *list.List
+	cache        map[Key]*list.Element
+	seen         map[Key]struct{}
+	dependents   map[Key][]Key
+	gen          uint64
+	totalAdded   uint64
+	totalRemoved uint64
+	emptied      bool
+	oplog        []Op[Key]
+	opNext       int
+	opLen        int
+	hot          map[Key]uint64
+	window       *hitWindow
+	stats        Stats
+	watchers     map[Key][]chan Value
+	pendingN     int
+	round        uint64
+	failures     map[Key]failure
+	captured     *[]struct {
+		K Key
+		V Value
+	}
+	deferred *[]*entry[Key, Value]
+}
+
+// failure is a loader error cached by GetOrAddWithErrorCaching.
+type failure struct {
+	err      error
+	deadline time.Time
+}
 
-	ll    *list.List
-	cache map[Key]*list.Element
+type accessTimes struct {
+	first, last time.Time
 }
 
 type entry[Key, Value any] struct {
 	key   Key
 	value Value
+	deps  []Key
+	gen   uint64
+	boost int
+	// deadline is the time after which the entry expires, or the zero
+	// time if the entry never expires.
+	deadline time.Time
+	// access holds the entry's access times, or nil if they are not
+	// being recorded.
+	access *accessTimes
+	// pending is the eviction round in which the entry became a pending
+	// eviction, or zero if it is not pending.
+	pending uint64
+	// inserted is the entry's element in the insertion order list, or
+	// nil if insertion order is not being tracked.
+	inserted *list.Element
 }
 
 // New creates a new policy-driven Cache.
@@ -86,102 +503,1771 @@ func NewWithHandler[Key comparable, Value any](policy Policy[Key, Value], handle
 	}
 }
 
+// NewTracked creates a new Cache whose policy and handler are both ph.
+//
+// NewTracked(ph) is equivalent to NewWithHandler(ph, ph), but cannot be
+// misconfigured by accidentally passing a stateful policy as the policy
+// only, which would leave its state untracked.
+func NewTracked[Key comparable, Value any](ph PolicyHandler[Key, Value]) *Cache[Key, Value] {
+	return NewWithHandler[Key, Value](ph, ph)
+}
+
+// SetPolicy replaces the cache eviction policy. It is equivalent to
+// assigning the Policy field. The new policy is not applied until the
+// next call to Add or Evict.
+func (c *Cache[Key, Value]) SetPolicy(p Policy[Key, Value]) {
+	c.Policy = p
+}
+
+// Bounded reports whether the cache has an eviction policy which can
+// evict items. It is false if Policy is nil, or if Policy is a built-in
+// policy which wraps other policies, such as EvictPreferring, and none
+// of the policies it wraps is bounded. Any other non-nil policy is
+// assumed to be able to evict items.
+func (c *Cache[Key, Value]) Bounded() bool {
+	return bounded(c.Policy)
+}
+
+// SetHandler replaces the cache event handler. It is equivalent to
+// assigning the Handler field.
+func (c *Cache[Key, Value]) SetHandler(h Handler[Key, Value]) {
+	c.Handler = h
+}
+
 // Add adds a value to the cache.
+//
+// If the key is already in the cache, its value is updated and any
+// dependencies it was given by AddWithDeps are kept.
+//
+// If Overflow is OverflowReject or OverflowError, a new key is not added
+// if adding it would require items to be evicted. Use AddOrErr to find
+// out whether a key was rejected.
+//
+// A new key is added as the most recently used item before the eviction
+// policy runs, so the policy considers every older item first, and the
+// new key is only evicted if the policy still decides to evict it once
+// it is the oldest item left. In particular, adding a new key to a full
+// cache limited by MaxCount(1) evicts the old key and keeps the new
+// one, while adding the key already in it updates its value in place
+// without evicting anything. If the Handler adds keys while the policy
+// is running, for example by re-adding an evicted key, those keys are
+// more recently used than the new key, which may then be evicted in
+// their place.
 func (c *Cache[Key, Value]) Add(k Key, v Value) {
-	if c.cache == nil {
-		c.ll = list.New()
-		c.cache = make(map[Key]*list.Element)
+	_ = c.AddOrErr(k, v)
+}
+
+// AddOrErr adds a value to the cache, like Add, and returns
+// ErrCacheFull if the value was rejected because adding it would
+// require items to be evicted and Overflow is OverflowError, or
+// ErrValueTooLarge if the value was rejected because it exceeds
+// MaxValueSize.
+func (c *Cache[Key, Value]) AddOrErr(k Key, v Value) error {
+	if c.tooLarge(v) {
+		return ErrValueTooLarge
+	}
+	k = c.normalize(k)
+	return c.settle(c.add(k, v, nil, false))
+}
+
+// AddBatch adds several values to the cache, like Add, in the order
+// given, so the last entry becomes the most recently used item. The
+// eviction policy is run once, after the last entry is added, rather
+// than after each entry, which makes AddBatch cheaper than calling Add
+// in a loop for bulk loading. The Handler, if any, receives an Added
+// event for each entry.
+//
+// If Overflow is OverflowReject or OverflowError, new keys which would
+// require items to be evicted are rejected, as by Add, but no error is
+// reported. Entries whose values exceed MaxValueSize are skipped.
+func (c *Cache[Key, Value]) AddBatch(entries []struct {
+	K Key
+	V Value
+}) {
+	for _, kv := range entries {
+		if c.tooLarge(kv.V) {
+			continue
+		}
+		k := c.normalize(kv.K)
+		_, _ = c.add(k, kv.V, nil, false)
+	}
+	c.Evict()
+}
+
+// settle finishes adding a key, given the results of add, by running
+// the eviction policy if a new key was inserted, and returns the error,
+// if any, which AddOrErr reports for a rejected key.
+func (c *Cache[Key, Value]) settle(inserted bool, err error) error {
+	if err != nil {
+		if c.Overflow == OverflowError {
+			return err
+		}
+		return nil
+	}
+	if inserted {
+		c.Evict()
+	}
+	return nil
+}
+
+// tooLarge reports whether the value v exceeds MaxValueSize.
+func (c *Cache[Key, Value]) tooLarge(v Value) bool {
+	return c.MaxValueSize > 0 && c.ValueSize != nil && c.ValueSize(v) > c.MaxValueSize
+}
+
+// admits reports whether the new key k, with the value v, may be
+// added to the cache under the overflow policy. Unless Overflow is
+// OverflowEvict, the key is only admitted if the eviction policy would
+// not evict anything from the cache with the key added.
+func (c *Cache[Key, Value]) admits(k Key, v Value) bool {
+	p := c.Policy
+	if c.Overflow == OverflowEvict || p == nil {
+		return true
+	}
+	n := c.ll.Len() - c.pendingN + 1
+	if a, ok := p.(admitter[Key, Value]); ok {
+		return a.fits(k, v, n)
+	}
+	if s, ok := c.selector(); ok {
+		_, ok = s.Select(n)
+		return !ok
+	}
+	if ele := skipPending[Key, Value](c.ll.Back()); ele != nil {
+		e := ele.Value.(*entry[Key, Value])
+		return !p.Evict(e.key, e.value, n)
+	}
+	return !p.Evict(k, v, n)
+}
+
+// overflowing reports whether the eviction policy would evict anything
+// from the cache as it stands.
+func (c *Cache[Key, Value]) overflowing() bool {
+	p := c.Policy
+	if p == nil || c.cache == nil || c.ll.Len() == c.pendingN {
+		return false
+	}
+	n := c.ll.Len() - c.pendingN
+	if s, ok := c.selector(); ok {
+		_, ok = s.Select(n)
+		return ok
 	}
+	e := skipPending[Key, Value](c.ll.Back()).Value.(*entry[Key, Value])
+	return p.Evict(e.key, e.value, n)
+}
+
+// AddUntil adds a value to the cache, like Add, which expires at the
+// given deadline. Once the deadline has passed, Get and the other
+// lookup methods treat the entry as a miss and remove it from the
+// cache. Until then, the entry is subject to the eviction policy like
+// any other.
+//
+// Only entries added by AddUntil expire by time. If the key is later
+// updated by Add, its deadline is cleared, and if it is updated by
+// AddUntil, its deadline is replaced.
+func (c *Cache[Key, Value]) AddUntil(k Key, v Value, deadline time.Time) {
+	if c.tooLarge(v) {
+		return
+	}
+	k = c.normalize(k)
+	inserted, err := c.add(k, v, nil, false)
+	if ele, ok := c.cache[k]; ok {
+		ele.Value.(*entry[Key, Value]).deadline = deadline
+	}
+	_ = c.settle(inserted, err)
+}
+
+// AddWithDeps adds a value to the cache which depends on the values
+// of the keys in dependsOn. When any of the keys in dependsOn is
+// removed from the cache, whether by the eviction policy or by a
+// direct call to Remove, the added entry is removed with it. Removal
+// cascades, so entries which depend on the added entry are removed as
+// well, and so on. Cycles of dependencies are allowed, and are broken
+// by removing each entry in the cycle exactly once.
+//
+// The keys in dependsOn need not be in the cache when AddWithDeps is
+// called. If the key k is already in the cache, its value is updated
+// and its previous dependencies are replaced by dependsOn.
+//
+// The Handler, if any, receives a Removed event for each entry removed
+// by cascading, immediately after the event for the entry it depends
+// on.
+func (c *Cache[Key, Value]) AddWithDeps(k Key, v Value, dependsOn []Key) {
+	if c.tooLarge(v) {
+		return
+	}
+	deps := make([]Key, len(dependsOn))
+	for i := range dependsOn {
+		deps[i] = c.normalize(dependsOn[i])
+	}
+	k = c.normalize(k)
+	_ = c.settle(c.add(k, v, deps, true))
+}
+
+// add adds or updates an entry without running the eviction policy,
+// and reports whether a new entry was inserted. If a new key is
+// rejected by the overflow policy, add returns ErrCacheFull.
+func (c *Cache[Key, Value]) add(k Key, v Value, deps []Key, setDeps bool) (inserted bool, err error) {
+	v = c.prepare(k, v)
 	h := c.Handler
 	if ele, ok := c.cache[k]; ok {
+		c.logOp(OpAdd, k, true)
 		c.ll.MoveToFront(ele)
 		e := ele.Value.(*entry[Key, Value])
+		c.rescue(e)
+		if setDeps {
+			c.unlinkDeps(k, e.deps)
+			c.linkDeps(k, e, deps)
+		}
 		old := e.value
 		e.value = v
+		e.gen = c.gen
+		e.deadline = time.Time{}
+		if e.access != nil {
+			e.access.last = c.now()
+		}
+		c.stats.Adds++
 		if h != nil {
 			h.Added(k, old, v, true)
 		}
-		return
+		c.notifyWatchers(k, v)
+		return false, nil
+	}
+	return c.insert(k, v, deps, setDeps)
+}
+
+// prepare readies the cache to store the value v under the key k,
+// initializing the cache if needed and forgetting any remembered error
+// for k, and returns the value to store.
+func (c *Cache[Key, Value]) prepare(k Key, v Value) Value {
+	if c.CopyOnAdd != nil {
+		v = c.CopyOnAdd(v)
+	}
+	if c.cache == nil {
+		if c.DisableLazyInit {
+			panic("policylru: Add called on uninitialized Cache with DisableLazyInit set")
+		}
+		c.ll = list.New()
+		c.cache = make(map[Key]*list.Element)
+	}
+	if c.failures != nil {
+		delete(c.failures, k)
+	}
+	return v
+}
+
+// insert inserts a new entry, which must not already be in the cache,
+// without running the eviction policy, and reports whether it was
+// inserted, like add. The value must already have been passed through
+// prepare.
+func (c *Cache[Key, Value]) insert(k Key, v Value, deps []Key, setDeps bool) (inserted bool, err error) {
+	h := c.Handler
+	c.logOp(OpAdd, k, false)
+	if cl, ok := c.Policy.(countLimiter); ok && cl.maxCount() <= 0 {
+		return false, nil
+	}
+	if !c.admits(k, v) {
+		return false, ErrCacheFull
+	}
+	if c.MaxDistinctKeys > 0 && !c.see(k) {
+		return false, nil
+	}
+	e := &entry[Key, Value]{key: k, value: v, gen: c.gen}
+	if c.RecordAccessTimes {
+		now := c.now()
+		e.access = &accessTimes{now, now}
+	}
+	c.cache[k] = c.ll.PushFront(e)
+	c.totalAdded++
+	c.stats.Adds++
+	c.trackInsertion(e)
+	if c.Bloom != nil {
+		c.Bloom.Add(k)
+	}
+	if setDeps {
+		c.linkDeps(k, e, deps)
 	}
-	ele := c.ll.PushFront(&entry[Key, Value]{k, v})
-	c.cache[k] = ele
 	if h != nil {
 		var old Value
 		h.Added(k, old, v, false)
 	}
-	c.Evict()
+	c.notifyWatchers(k, v)
+	return true, nil
+}
+
+func (c *Cache[Key, Value]) linkDeps(k Key, e *entry[Key, Value], deps []Key) {
+	if len(deps) == 0 {
+		e.deps = nil
+		return
+	}
+	e.deps = deps
+	if c.dependents == nil {
+		c.dependents = make(map[Key][]Key)
+	}
+	for _, dep := range deps {
+		c.dependents[dep] = append(c.dependents[dep], k)
+	}
+}
+
+func (c *Cache[Key, Value]) unlinkDeps(k Key, deps []Key) {
+	for _, dep := range deps {
+		dependents := c.dependents[dep]
+		i := 0
+		for _, d := range dependents {
+			if d != k {
+				dependents[i] = d
+				i++
+			}
+		}
+		if i == 0 {
+			delete(c.dependents, dep)
+		} else {
+			c.dependents[dep] = dependents[:i]
+		}
+	}
+}
+
+func (c *Cache[Key, Value]) see(k Key) bool {
+	if _, ok := c.seen[k]; ok {
+		return true
+	}
+	if len(c.seen) >= c.MaxDistinctKeys {
+		return false
+	}
+	if c.seen == nil {
+		c.seen = make(map[Key]struct{})
+	}
+	c.seen[k] = struct{}{}
+	return true
+}
+
+// AdmitNewKeys reports whether Add will admit a key it has not seen
+// before. It returns false only when MaxDistinctKeys is positive and
+// the cache has already seen that many distinct keys.
+func (c *Cache[Key, Value]) AdmitNewKeys() bool {
+	return c.MaxDistinctKeys <= 0 || len(c.seen) < c.MaxDistinctKeys
+}
+
+// DistinctKeys returns the number of distinct keys seen by Add since
+// the cache was created or ResetDistinctKeys was last called. Keys are
+// only counted while MaxDistinctKeys is positive.
+func (c *Cache[Key, Value]) DistinctKeys() int {
+	return len(c.seen)
+}
+
+// ResetDistinctKeys forgets the distinct keys seen by Add so far, so
+// that new keys are admitted again until MaxDistinctKeys is reached.
+// It does not remove any entries from the cache.
+func (c *Cache[Key, Value]) ResetDistinctKeys() {
+	c.seen = nil
 }
 
 // Get looks up a key's value from the cache.
 func (c *Cache[Key, Value]) Get(k Key) (v Value, hit bool) {
-	var ele *list.Element
-	if ele, hit = c.cache[k]; hit {
-		c.ll.MoveToFront(ele)
-		v = ele.Value.(*entry[Key, Value]).value
+	return c.get(c.normalize(k))
+}
+
+// get looks up the normalized key k, like Get.
+func (c *Cache[Key, Value]) get(k Key) (v Value, hit bool) {
+	if c.Bloom == nil || c.Bloom.MayContain(k) {
+		var ele *list.Element
+		if ele, hit = c.live(k); hit {
+			c.promote(ele, k)
+			v = c.copyOut(ele.Value.(*entry[Key, Value]).value)
+		}
+	}
+	c.lookedUp(k, hit)
+	if !hit && c.Fallback != nil {
+		v, hit = c.fallBack(k)
 	}
 	return
 }
 
-// Remove removes the provided key from the cache.
-func (c *Cache[Key, Value]) Remove(k Key) (removed bool) {
-	if ele, hit := c.cache[k]; hit {
-		c.removeElement(ele, k)
-		return true
+// Peek looks up a key's value from the cache, like Get, but without
+// promoting it or otherwise changing the cache. On a miss, Peek returns
+// the zero value, as Get does. An item which has passed its AddUntil
+// deadline is reported as a miss, but is not removed.
+func (c *Cache[Key, Value]) Peek(k Key) (v Value, hit bool) {
+	ele, ok := c.cache[c.normalize(k)]
+	if !ok {
+		return
 	}
-	return false
+	e := ele.Value.(*entry[Key, Value])
+	if !e.deadline.IsZero() && !c.now().Before(e.deadline) {
+		return
+	}
+	return c.copyOut(e.value), true
 }
 
-// Evict continuously removes the oldest item from cache as long as the
-// eviction policy returns true for that item. This process ends when
-// the policy returns false for the oldest item or the cache is empty.
-//
-// The value returned is the number of items removed.
-func (c *Cache[Key, Value]) Evict() (n int) {
-	p := c.Policy
-	if p == nil {
+// GetOldest returns the key and value of the least recently used item,
+// which is the next item the eviction policy will consider, without
+// promoting it or otherwise changing the cache. If the cache is empty,
+// ok is false.
+func (c *Cache[Key, Value]) GetOldest() (k Key, v Value, ok bool) {
+	if c.cache == nil {
 		return
 	}
 	ele := c.ll.Back()
-	for ele != nil {
-		e := ele.Value.(*entry[Key, Value])
-		if p.Evict(e.key, e.value, c.ll.Len()) {
-			c.removeElement(ele, e.key)
-			n++
-			ele = c.ll.Back()
+	if ele == nil {
+		return
+	}
+	e := ele.Value.(*entry[Key, Value])
+	return e.key, c.copyOut(e.value), true
+}
+
+// PeekAll looks up the values of several keys from the cache without
+// promoting any of them or otherwise changing the cache, and returns
+// the values found, keyed by the keys as given, and the keys which
+// were not found, in argument order. An item which has passed its
+// AddUntil deadline is reported as a miss, but is not removed.
+//
+// PeekAll is useful for reading several related items at once when
+// promoting them would distort the recency order.
+func (c *Cache[Key, Value]) PeekAll(keys []Key) (values map[Key]Value, misses []Key) {
+	values = make(map[Key]Value, len(keys))
+	for _, k := range keys {
+		if v, hit := c.Peek(k); hit {
+			values[k] = v
 		} else {
-			break
+			misses = append(misses, k)
 		}
 	}
 	return
 }
 
-func (c *Cache[Key, Value]) removeElement(ele *list.Element, k Key) {
-	c.ll.Remove(ele)
-	delete(c.cache, k)
-	h := c.Handler
-	if h != nil {
-		h.Removed(k, ele.Value.(*entry[Key, Value]).value)
+// GetMulti looks up the values of several keys from the cache, as if
+// by calling Get for each key in argument order, and returns the values
+// found, keyed by the keys as given, and the keys which were not found,
+// in argument order. Since each hit is promoted in turn, the last key
+// found becomes the most recently used item.
+func (c *Cache[Key, Value]) GetMulti(keys []Key) (found map[Key]Value, missing []Key) {
+	found = make(map[Key]Value, len(keys))
+	for _, k := range keys {
+		if v, hit := c.Get(k); hit {
+			found[k] = v
+		} else {
+			missing = append(missing, k)
+		}
 	}
+	return
 }
 
-// Len returns the number of items in the cache.
-func (c *Cache[Key, Value]) Len() int {
-	if c.cache == nil {
-		return 0
+// AccessInfo returns the time the key k was added to the cache and the
+// time it was last accessed, without promoting it. If k is not in the
+// cache, or it was added while RecordAccessTimes was not set, ok is
+// false.
+func (c *Cache[Key, Value]) AccessInfo(k Key) (first, last time.Time, ok bool) {
+	ele, hit := c.cache[c.normalize(k)]
+	if !hit {
+		return
 	}
-	return c.ll.Len()
+	a := ele.Value.(*entry[Key, Value]).access
+	if a == nil {
+		return
+	}
+	return a.first, a.last, true
 }
 
-// Clear purges all stored items from the cache.
-func (c *Cache[Key, Value]) Clear() {
-	cache := c.cache
-	c.ll = nil
-	c.cache = nil
-	h := c.Handler
-	if h != nil {
-		for _, ele := range cache {
+func (c *Cache[Key, Value]) now() time.Time {
+	if c.Clock != nil {
+		return c.Clock()
+	}
+	return time.Now()
+}
+
+// fallBack looks up the key k in the Fallback cache after a miss,
+// adding its value to the cache if it is found.
+func (c *Cache[Key, Value]) fallBack(k Key) (v Value, hit bool) {
+	if v, hit = c.Fallback.Get(k); hit {
+		c.Add(k, v)
+		v = c.copyOut(v)
+	}
+	return
+}
+
+func (c *Cache[Key, Value]) lookedUp(k Key, hit bool) {
+	c.logOp(OpGet, k, hit)
+	c.countHot(k)
+	if hit {
+		c.stats.Hits++
+	} else {
+		c.stats.Misses++
+	}
+	if c.window != nil {
+		c.window.record(hit)
+	}
+	if lh, ok := c.Handler.(LookupHandler[Key]); ok {
+		lh.LookedUp(k, hit)
+	}
+}
+
+// live looks up the element for the key k, removing it and reporting a
+// miss if its entry has passed its deadline or fails validation, and
+// otherwise recording the access time if access times are recorded.
+func (c *Cache[Key, Value]) live(k Key) (*list.Element, bool) {
+	ele, ok := c.cache[k]
+	if !ok {
+		return nil, false
+	}
+	e := ele.Value.(*entry[Key, Value])
+	if !e.deadline.IsZero() && !c.now().Before(e.deadline) ||
+		c.Validator != nil && !c.Validator(k, e.value) {
+		c.removeElement(ele, k)
+		return nil, false
+	}
+	if e.access != nil {
+		e.access.last = c.now()
+	}
+	return ele, true
+}
+
+func (c *Cache[Key, Value]) copyOut(v Value) Value {
+	if c.CopyOnGet != nil {
+		return c.CopyOnGet(v)
+	}
+	return v
+}
+
+// GetOrDefault looks up a key's value from the cache, like Get. If the
+// key is not in the cache, GetOrDefault returns the value supplied by
+// the DefaultValue function, or the zero value if DefaultValue is nil.
+//
+// Unlike a read-through cache, GetOrDefault does not add the default
+// value to the cache, so DefaultValue is called on every miss.
+func (c *Cache[Key, Value]) GetOrDefault(k Key) Value {
+	if v, hit := c.Get(k); hit {
+		return v
+	}
+	if c.DefaultValue != nil {
+		return c.DefaultValue(c.normalize(k))
+	}
+	var zero Value
+	return zero
+}
+
+// GetOrAdd looks up a key's value from the cache, like Get, and on a
+// miss calls f to produce the value, adds it to the cache, as if by Add,
+// and returns it. The function f is only called on a miss. The result
+// loaded is true if the value was found in the cache and false if it
+// was produced by f.
+//
+// The key is normalized once for both the lookup and the add. The
+// function f may itself add k to the cache, for example when memoizing
+// a recursive computation, in which case the value produced by f
+// updates the value f added.
+func (c *Cache[Key, Value]) GetOrAdd(k Key, f func() Value) (v Value, loaded bool) {
+	k = c.normalize(k)
+	if v, loaded = c.get(k); loaded {
+		return
+	}
+	v = f()
+	if !c.tooLarge(v) {
+		_ = c.settle(c.add(k, v, nil, false))
+	}
+	return c.copyOut(v), false
+}
+
+// GetOrAddWithErrorCaching looks up a key's value from the cache, like
+// Get, and on a miss calls f to load the value. If f succeeds, its
+// value is added to the cache, as if by Add, and returned. If f fails,
+// its error is returned, and is also remembered for errorTTL, during
+// which GetOrAddWithErrorCaching returns the same error for k without
+// calling f again. This protects a failing backend from being hammered
+// by retries. Once errorTTL has passed, the next call tries f again.
+//
+// Remembered errors are kept apart from the cached values, so they do
+// not count towards the eviction policy's limits and generate no
+// Handler events. A remembered error is forgotten when its key is next
+// looked up by GetOrAddWithErrorCaching after it expires, when another
+// error is remembered after it expires, when its key is added or
+// removed, or when the cache is cleared.
+func (c *Cache[Key, Value]) GetOrAddWithErrorCaching(k Key, f func() (Value, error), errorTTL time.Duration) (Value, error) {
+	if v, hit := c.Get(k); hit {
+		return v, nil
+	}
+	k = c.normalize(k)
+	if fail, ok := c.failures[k]; ok {
+		if c.now().Before(fail.deadline) {
+			var zero Value
+			return zero, fail.err
+		}
+		delete(c.failures, k)
+	}
+	v, err := f()
+	if err != nil {
+		if errorTTL > 0 {
+			c.rememberFailure(k, err, errorTTL)
+		}
+		return v, err
+	}
+	c.Add(k, v)
+	return c.copyOut(v), nil
+}
+
+// rememberFailure remembers the error err for the key k until errorTTL
+// has passed. Remembered errors whose keys are never looked up again
+// would otherwise stay forever, so the expired ones are swept out each
+// time a new one is remembered, keeping the remembered errors bounded
+// by the number of keys which failed within their errorTTL.
+func (c *Cache[Key, Value]) rememberFailure(k Key, err error, errorTTL time.Duration) {
+	now := c.now()
+	if c.failures == nil {
+		c.failures = make(map[Key]failure)
+	}
+	for k2, fail := range c.failures {
+		if !now.Before(fail.deadline) {
+			delete(c.failures, k2)
+		}
+	}
+	c.failures[k] = failure{err, now.Add(errorTTL)}
+}
+
+// GetWithStale looks up a key's value from the cache, like Get, and
+// additionally reports whether the value is stale, meaning it was
+// added before the most recent call to BumpGeneration.
+//
+// Stale values are otherwise treated like any other value: they are
+// returned, promoted and retained until the eviction policy removes
+// them or they are replaced by Add. This supports serving stale values
+// while they are revalidated.
+func (c *Cache[Key, Value]) GetWithStale(k Key) (v Value, stale, hit bool) {
+	k = c.normalize(k)
+	if c.Bloom == nil || c.Bloom.MayContain(k) {
+		var ele *list.Element
+		if ele, hit = c.live(k); hit {
+			c.promote(ele, k)
 			e := ele.Value.(*entry[Key, Value])
+			v, stale = c.copyOut(e.value), e.gen != c.gen
+		}
+	}
+	c.lookedUp(k, hit)
+	if !hit && c.Fallback != nil {
+		v, hit = c.fallBack(k)
+	}
+	return
+}
+
+// BumpGeneration marks every value currently in the cache as stale,
+// as reported by GetWithStale, without removing anything. Values added
+// to the cache afterwards are fresh until the next BumpGeneration.
+func (c *Cache[Key, Value]) BumpGeneration() {
+	c.gen++
+}
+
+// DefinitelyAbsent returns true if the key k has definitely never been
+// added to the cache since the Bloom filter was installed, and false
+// otherwise. A false result does not mean k is in the cache: it may
+// have been removed, or the filter may have reported a false positive.
+// If Bloom is nil, DefinitelyAbsent always returns false.
+func (c *Cache[Key, Value]) DefinitelyAbsent(k Key) bool {
+	return c.Bloom != nil && !c.Bloom.MayContain(c.normalize(k))
+}
+
+// TouchAll promotes each of the given keys which is in the cache to
+// most recently used, in argument order, so the last key present
+// becomes the most recently used item. Keys which are not in the cache
+// are skipped. The value returned is the number of keys promoted.
+//
+// TouchAll promotes keys exactly as Get does, without retrieving their
+// values.
+func (c *Cache[Key, Value]) TouchAll(keys ...Key) (n int) {
+	for _, k := range keys {
+		k = c.normalize(k)
+		if ele, ok := c.cache[k]; ok {
+			c.promote(ele, k)
+			n++
+		}
+	}
+	return
+}
+
+func (c *Cache[Key, Value]) promote(ele *list.Element, k Key) {
+	c.rescue(ele.Value.(*entry[Key, Value]))
+	if ele == c.ll.Front() {
+		return
+	}
+	ph, ok := c.Handler.(PromotionHandler[Key])
+	if !ok {
+		c.ll.MoveToFront(ele)
+		return
+	}
+	from := -1
+	if c.RankPromotions {
+		from = 0
+		for e := ele.Prev(); e != nil; e = e.Prev() {
+			from++
+		}
+	}
+	c.ll.MoveToFront(ele)
+	ph.Promoted(k, from, 0)
+}
+
+// Remove removes the provided key from the cache.
+func (c *Cache[Key, Value]) Remove(k Key) (removed bool) {
+	k = c.normalize(k)
+	if c.failures != nil {
+		delete(c.failures, k)
+	}
+	ele, hit := c.cache[k]
+	c.logOp(OpRemove, k, hit)
+	if hit {
+		c.removeElement(ele, k)
+	}
+	return hit
+}
+
+// RemoveMulti removes each of the provided keys from the cache, as if
+// by calling Remove for each key in argument order, and returns the
+// number of keys removed. Keys which are not in the cache are skipped.
+// Items removed only because they depend, through AddWithDeps, on a
+// removed key are not counted.
+func (c *Cache[Key, Value]) RemoveMulti(keys []Key) (n int) {
+	for _, k := range keys {
+		if c.Remove(k) {
+			n++
+		}
+	}
+	return
+}
+
+// RemoveOldest removes the least recently used item from the cache and
+// returns its key and value. If the cache is empty, ok is false.
+//
+// RemoveOldest lets the caller bound the cache without a Policy, by
+// removing items until the cache satisfies its own limit.
+func (c *Cache[Key, Value]) RemoveOldest() (k Key, v Value, ok bool) {
+	if c.cache == nil {
+		return
+	}
+	ele := c.ll.Back()
+	if ele == nil {
+		return
+	}
+	e := ele.Value.(*entry[Key, Value])
+	// Removing the element may scrub the entry's value, so take a copy
+	// of it first.
+	k, v = e.key, e.value
+	c.logOp(OpRemove, k, true)
+	c.removeElement(ele, k)
+	return k, v, true
+}
+
+// Evict continuously removes the oldest item from cache as long as the
+// eviction policy returns true for that item. This process ends when
+// the policy returns false for the oldest item or the cache is empty.
+//
+// If the policy implements Selector, Evict instead repeatedly removes
+// the item the policy selects, until the policy selects no item.
+//
+// If several items are removed, the Handler receives their Removed
+// events in the order the items were removed, oldest item first,
+// unless ReverseEvictionEvents is set.
+//
+// The value returned is the number of items removed.
+func (c *Cache[Key, Value]) Evict() (n int) {
+	p := c.Policy
+	if p == nil || c.cache == nil {
+		return
+	}
+	if c.ReverseEvictionEvents && c.Handler != nil {
+		var deferred []*entry[Key, Value]
+		prev := c.deferred
+		c.deferred = &deferred
+		defer func() {
+			c.deferred = prev
+			for i := len(deferred) - 1; i >= 0; i-- {
+				c.removed(deferred[i])
+			}
+			if prev == nil {
+				c.notifyEmpty()
+			}
+		}()
+	}
+	if c.TwoPhaseEviction {
+		c.round++
+		if c.EvictionGrace > 0 {
+			grace := uint64(c.EvictionGrace)
+			c.commitPending(func(e *entry[Key, Value]) bool {
+				return e.pending+grace <= c.round
+			})
+		}
+	}
+	if s, ok := c.selector(); ok {
+		return c.evictSelected(s)
+	}
+	// Boosted and retained items which survive are skipped over, so
+	// after the first one, the walk continues from the item before the
+	// last one kept. Pending evictions are skipped over likewise.
+	var kept *list.Element
+	r, _ := p.(Retainer[Key, Value])
+	ele := skipPending[Key, Value](c.ll.Back())
+	for ele != nil {
+		e := ele.Value.(*entry[Key, Value])
+		if !p.Evict(e.key, e.value, c.ll.Len()-c.pendingN) {
+			break
+		}
+		retained := r != nil && r.Retain(e.key, e.value)
+		if retained || e.boost > 0 {
+			if !retained {
+				e.boost--
+			}
+			kept = ele
+			ele = skipPending[Key, Value](ele.Prev())
+			continue
+		}
+		attributed := c.attribution(e.key)
+		if pr, ok := p.(Preferrer[Key, Value]); ok {
+			ele, e = c.preferred(pr, ele, e, nil)
+		}
+		attributed(e.key)
+		c.evictElement(ele, e)
+		n++
+		if kept == nil {
+			ele = skipPending[Key, Value](c.ll.Back())
+		} else {
+			ele = skipPending[Key, Value](kept.Prev())
+		}
+	}
+	return
+}
+
+// skipPending returns the first element, starting at ele and moving
+// towards the front of the list, which is not a pending eviction.
+func skipPending[Key, Value any](ele *list.Element) *list.Element {
+	for ele != nil && ele.Value.(*entry[Key, Value]).pending != 0 {
+		ele = ele.Prev()
+	}
+	return ele
+}
+
+// rescue makes the entry e no longer a pending eviction.
+func (c *Cache[Key, Value]) rescue(e *entry[Key, Value]) {
+	if e.pending != 0 {
+		e.pending = 0
+		c.pendingN--
+	}
+}
+
+// PendingEvictions returns the items which are pending eviction
+// because TwoPhaseEviction is set, from least to most recently used.
+// The cache is not changed.
+func (c *Cache[Key, Value]) PendingEvictions() []struct {
+	K Key
+	V Value
+} {
+	pending := make([]struct {
+		K Key
+		V Value
+	}, 0, c.pendingN)
+	if c.pendingN == 0 {
+		return pending
+	}
+	for ele := c.ll.Back(); ele != nil && len(pending) < c.pendingN; ele = ele.Prev() {
+		if e := ele.Value.(*entry[Key, Value]); e.pending != 0 {
+			pending = append(pending, struct {
+				K Key
+				V Value
+			}{e.key, c.copyOut(e.value)})
+		}
+	}
+	return pending
+}
+
+// CommitEvictions removes all the items which are pending eviction
+// because TwoPhaseEviction is set, least recently used first, and
+// returns the number of items removed.
+func (c *Cache[Key, Value]) CommitEvictions() int {
+	return c.commitPending(func(*entry[Key, Value]) bool { return true })
+}
+
+// commitPending removes the pending evictions for which due returns
+// true, least recently used first.
+func (c *Cache[Key, Value]) commitPending(due func(e *entry[Key, Value]) bool) (n int) {
+	if c.pendingN == 0 {
+		return
+	}
+	var eles []*list.Element
+	for ele := c.ll.Back(); ele != nil && len(eles) < c.pendingN; ele = ele.Prev() {
+		if ele.Value.(*entry[Key, Value]).pending != 0 {
+			eles = append(eles, ele)
+		}
+	}
+	for _, ele := range eles {
+		e := ele.Value.(*entry[Key, Value])
+		// Earlier removals may have cascaded to, or rescued, this one.
+		if e.pending == 0 || c.cache[e.key] != ele || !due(e) {
+			continue
+		}
+		c.evictNow(ele, e)
+		n++
+	}
+	return
+}
+
+// preferred finds the oldest unboosted item, starting at the element
+// ele, which the Preferrer prefers to evict, or returns ele if there
+// is none. Items whose keys are in gone are skipped.
+func (c *Cache[Key, Value]) preferred(pr Preferrer[Key, Value], ele *list.Element, e *entry[Key, Value], gone map[Key]bool) (*list.Element, *entry[Key, Value]) {
+	for p := ele; p != nil; p = p.Prev() {
+		pe := p.Value.(*entry[Key, Value])
+		if pe.boost == 0 && pe.pending == 0 && !gone[pe.key] && pr.Prefer(pe.key, pe.value) {
+			return p, pe
+		}
+	}
+	return ele, e
+}
+
+// selector returns the Selector which chooses the items to evict, if
+// the eviction policy chooses them itself. This is the policy itself if
+// it implements Selector, or an adapter for a built-in policy which
+// combines policies, at least one of which implements Selector.
+func (c *Cache[Key, Value]) selector() (Selector[Key], bool) {
+	if cs, ok := c.Policy.(compositeSelector[Key, Value]); ok {
+		if !cs.selects() {
+			return nil, false
+		}
+		return viewSelector[Key, Value]{c, cs}, true
+	}
+	s, ok := c.Policy.(Selector[Key])
+	return s, ok
+}
+
+// viewSelector adapts a compositeSelector to Selector by giving it a
+// view of the cache.
+type viewSelector[Key comparable, Value any] struct {
+	c  *Cache[Key, Value]
+	cs compositeSelector[Key, Value]
+}
+
+func (s viewSelector[Key, Value]) Select(n int) (Key, bool) {
+	return s.cs.selectFrom(n, s.c)
+}
+
+func (c *Cache[Key, Value]) oldest() (k Key, v Value, ok bool) {
+	if c.cache == nil {
+		return
+	}
+	ele := skipPending[Key, Value](c.ll.Back())
+	if ele == nil {
+		return
+	}
+	e := ele.Value.(*entry[Key, Value])
+	return e.key, e.value, true
+}
+
+func (c *Cache[Key, Value]) value(k Key) (v Value, ok bool) {
+	ele, ok := c.cache[k]
+	if !ok {
+		return
+	}
+	return ele.Value.(*entry[Key, Value]).value, true
+}
+
+func (c *Cache[Key, Value]) excluded() func(Key) bool {
+	return nil
+}
+
+// simulation is a view of the cache in which the items whose keys are
+// in gone are treated as already evicted, for SurvivingKeys.
+type simulation[Key comparable, Value any] struct {
+	c    *Cache[Key, Value]
+	gone map[Key]bool
+}
+
+func (s simulation[Key, Value]) oldest() (k Key, v Value, ok bool) {
+	ele := s.skip(s.c.ll.Back())
+	if ele == nil {
+		return
+	}
+	e := ele.Value.(*entry[Key, Value])
+	return e.key, e.value, true
+}
+
+func (s simulation[Key, Value]) value(k Key) (v Value, ok bool) {
+	if s.gone[k] {
+		return
+	}
+	return s.c.value(k)
+}
+
+func (s simulation[Key, Value]) excluded() func(Key) bool {
+	return func(k Key) bool { return s.gone[k] }
+}
+
+// skip returns the first element, starting at ele and moving towards
+// the front of the list, which is neither a pending eviction nor
+// treated as evicted.
+func (s simulation[Key, Value]) skip(ele *list.Element) *list.Element {
+	for ele != nil {
+		e := ele.Value.(*entry[Key, Value])
+		if e.pending == 0 && !s.gone[e.key] {
+			break
+		}
+		ele = ele.Prev()
+	}
+	return ele
+}
+
+func (c *Cache[Key, Value]) evictSelected(s Selector[Key]) (n int) {
+	for {
+		k, ok := s.Select(c.ll.Len() - c.pendingN)
+		if !ok {
+			return
+		}
+		ele, hit := c.cache[k]
+		if !hit {
+			return
+		}
+		e := ele.Value.(*entry[Key, Value])
+		if e.pending != 0 {
+			return
+		}
+		if e.boost > 0 {
+			e.boost--
+			return
+		}
+		c.attribution(k)(k)
+		c.evictElement(ele, e)
+		n++
+	}
+}
+
+// attribution works out which policy decided to evict the item with
+// key k, and returns a function which reports the eviction of an item,
+// k or one preferred over it, to the Handler if it implements
+// EvictionHandler. The decision must be attributed before any item is
+// removed, while the cache is in the state the policy decided on.
+func (c *Cache[Key, Value]) attribution(k Key) func(Key) {
+	eh, ok := c.Handler.(EvictionHandler[Key])
+	if !ok {
+		return func(Key) {}
+	}
+	policy := -1
+	if a, ok := c.Policy.(attributor[Key, Value]); ok {
+		policy = a.attribute(k, c.ll.Len()-c.pendingN, c)
+	}
+	return func(k Key) {
+		eh.Evicting(k, policy)
+	}
+}
+
+// evictElement evicts the element ele, or makes it a pending eviction
+// if TwoPhaseEviction is set.
+func (c *Cache[Key, Value]) evictElement(ele *list.Element, e *entry[Key, Value]) {
+	if c.TwoPhaseEviction {
+		e.pending = c.round
+		c.pendingN++
+		return
+	}
+	c.evictNow(ele, e)
+}
+
+func (c *Cache[Key, Value]) evictNow(ele *list.Element, e *entry[Key, Value]) {
+	c.logOp(OpEvict, e.key, true)
+	c.stats.Evictions++
+	if c.captured != nil {
+		*c.captured = append(*c.captured, struct {
+			K Key
+			V Value
+		}{e.key, e.value})
+	}
+	c.removeElement(ele, e.key)
+}
+
+// Boost protects the item with key k from the eviction policy for the
+// next passes calls to Evict, including the implicit calls made by Add,
+// in which the policy decides to evict it. Each time the policy decides
+// to evict a boosted item, the item survives, its remaining boost is
+// reduced by one, and Evict moves on to consider the next oldest item.
+// Once its boost is used up, the item is evicted normally.
+//
+// If the eviction policy implements Selector, a boosted item selected
+// for eviction survives, using up one unit of its boost, and the call
+// to Evict ends without evicting anything further.
+//
+// Boost replaces any boost the item already has, so a passes value of
+// zero or less removes the item's protection. Boost does not change
+// the recency of the item and does not protect it from Remove or
+// Clear. Boost returns false if k is not in the cache.
+func (c *Cache[Key, Value]) Boost(k Key, passes int) bool {
+	ele, ok := c.cache[c.normalize(k)]
+	if !ok {
+		return false
+	}
+	if passes < 0 {
+		passes = 0
+	}
+	ele.Value.(*entry[Key, Value]).boost = passes
+	return true
+}
+
+// SurvivingKeys returns the keys which would remain in the cache if
+// Evict were called now, ordered from least to most recently used. The
+// cache is not changed.
+//
+// Items which are pending eviction because TwoPhaseEviction is set are
+// not included.
+//
+// SurvivingKeys simulates Evict by choosing the items to evict in the
+// same way, with the item count reduced by one for each item it
+// hypothetically evicts: boosted items and items retained by a
+// Retainer policy survive, a Preferrer's preferred items are chosen
+// first, and the built-in Selector policies, alone or combined by And,
+// Or and EvictPreferring, choose as if the items already chosen were
+// gone. Boosts are not used up. A policy which tracks its state using
+// Handler events, such as one which limits the total size of the
+// cache, does not see the hypothetical removals, so the result for
+// such a policy only reflects its first decision. Removals cascading
+// from entries added by AddWithDeps are not simulated. A Selector
+// which is not one of the built-in policies cannot be consulted about
+// hypothetical removals, so for such a policy SurvivingKeys returns
+// all the keys in the cache.
+func (c *Cache[Key, Value]) SurvivingKeys() []Key {
+	if c.cache == nil {
+		return []Key{}
+	}
+	sim := simulation[Key, Value]{c, make(map[Key]bool)}
+	c.simulateEvict(sim)
+	keys := make([]Key, 0, c.ll.Len()-c.pendingN-len(sim.gone))
+	for ele := sim.skip(c.ll.Back()); ele != nil; ele = sim.skip(ele.Prev()) {
+		keys = append(keys, ele.Value.(*entry[Key, Value]).key)
+	}
+	return keys
+}
+
+// simulateEvict records in sim the keys of the items Evict would evict,
+// without changing the cache.
+func (c *Cache[Key, Value]) simulateEvict(sim simulation[Key, Value]) {
+	p := c.Policy
+	if p == nil || !simulable(p) {
+		return
+	}
+	n := c.ll.Len() - c.pendingN
+	if selecting(p) {
+		for {
+			k, ok := selection[Key, Value](p, n, sim)
+			if !ok || sim.gone[k] {
+				return
+			}
+			ele, hit := c.cache[k]
+			if !hit {
+				return
+			}
+			if e := ele.Value.(*entry[Key, Value]); e.pending != 0 || e.boost > 0 {
+				return
+			}
+			sim.gone[k] = true
+			n--
+		}
+	}
+	var kept *list.Element
+	r, _ := p.(Retainer[Key, Value])
+	pr, _ := p.(Preferrer[Key, Value])
+	ele := sim.skip(c.ll.Back())
+	for ele != nil {
+		e := ele.Value.(*entry[Key, Value])
+		if !p.Evict(e.key, e.value, n) {
+			return
+		}
+		if e.boost > 0 || r != nil && r.Retain(e.key, e.value) {
+			kept = ele
+			ele = sim.skip(ele.Prev())
+			continue
+		}
+		if pr != nil {
+			_, e = c.preferred(pr, ele, e, sim.gone)
+		}
+		sim.gone[e.key] = true
+		n--
+		if kept == nil {
+			ele = sim.skip(c.ll.Back())
+		} else {
+			ele = sim.skip(kept.Prev())
+		}
+	}
+}
+
+// WithEvictionCapture calls f and returns the entries evicted by the
+// eviction policy while f was running, in the order they were evicted.
+//
+// Only evictions are captured. Entries removed by a direct call to
+// Remove or Clear are not included in the result. Calls may be nested,
+// in which case the entries captured by the inner call are also
+// returned by the outer one.
+func (c *Cache[Key, Value]) WithEvictionCapture(f func()) []struct {
+	K Key
+	V Value
+} {
+	prev := c.captured
+	var captured []struct {
+		K Key
+		V Value
+	}
+	c.captured = &captured
+	defer func() {
+		c.captured = prev
+		if prev != nil {
+			*prev = append(*prev, captured...)
+		}
+	}()
+	f()
+	return captured
+}
+
+func (c *Cache[Key, Value]) removeElement(ele *list.Element, k Key) {
+	c.ll.Remove(ele)
+	delete(c.cache, k)
+	c.totalRemoved++
+	if c.ll.Len() == 0 {
+		c.emptied = true
+	}
+	e := ele.Value.(*entry[Key, Value])
+	c.rescue(e)
+	if e.inserted != nil {
+		c.insertion.Remove(e.inserted)
+	}
+	c.unlinkDeps(k, e.deps)
+	c.removed(e)
+	c.cascade(k)
+	if c.deferred == nil {
+		c.notifyEmpty()
+	}
+}
+
+// notifyEmpty calls OnEmpty if the cache was emptied by a removal and
+// is still empty.
+func (c *Cache[Key, Value]) notifyEmpty() {
+	if !c.emptied {
+		return
+	}
+	c.emptied = false
+	if c.OnEmpty != nil && c.ll.Len() == 0 {
+		c.OnEmpty()
+	}
+}
+
+// removed generates the Removed event for the removed entry e, unless
+// events are being deferred, and then scrubs its value.
+func (c *Cache[Key, Value]) removed(e *entry[Key, Value]) {
+	if c.deferred != nil {
+		*c.deferred = append(*c.deferred, e)
+		return
+	}
+	h := c.Handler
+	if h != nil {
+		h.Removed(e.key, e.value)
+	}
+	c.closeWatchers(e.key)
+	c.scrub(e)
+}
+
+func (c *Cache[Key, Value]) scrub(e *entry[Key, Value]) {
+	if c.ZeroOnRemove != nil {
+		c.ZeroOnRemove(&e.value)
+	}
+}
+
+func (c *Cache[Key, Value]) cascade(k Key) {
+	dependents, ok := c.dependents[k]
+	if !ok {
+		return
+	}
+	delete(c.dependents, k)
+	for _, d := range dependents {
+		if ele, hit := c.cache[d]; hit {
+			c.removeElement(ele, d)
+		}
+	}
+}
+
+// Repair rebuilds the cache's internal key index from its recency
+// list, fixing any inconsistencies between the two, and returns the
+// number of inconsistencies fixed.
+//
+// The recency list is treated as authoritative. Index entries that do
+// not refer to an element of the list are dropped, missing index
+// entries are restored, and if a key appears in the list more than
+// once, only its most recently used element is kept. No Handler events
+// are generated for entries dropped by Repair.
+//
+// Repair is a last-resort recovery operation. It is safe to call on a
+// healthy cache, in which case it changes nothing and returns 0.
+func (c *Cache[Key, Value]) Repair() (fixed int) {
+	if c.cache == nil {
+		return
+	}
+	cache := make(map[Key]*list.Element, c.ll.Len())
+	for ele := c.ll.Front(); ele != nil; {
+		next := ele.Next()
+		k := ele.Value.(*entry[Key, Value]).key
+		if _, dup := cache[k]; dup {
+			c.ll.Remove(ele)
+			fixed++
+		} else {
+			cache[k] = ele
+		}
+		ele = next
+	}
+	for k, ele := range c.cache {
+		if cache[k] != ele {
+			fixed++
+		}
+	}
+	for k := range cache {
+		if _, ok := c.cache[k]; !ok {
+			fixed++
+		}
+	}
+	c.cache = cache
+	return
+}
+
+func (c *Cache[Key, Value]) normalize(k Key) Key {
+	if c.KeyNormalizer != nil {
+		return c.KeyNormalizer(k)
+	}
+	return k
+}
+
+func (c *Cache[Key, Value]) trackInsertion(e *entry[Key, Value]) {
+	if !c.TrackInsertionOrder {
+		return
+	}
+	if c.insertion == nil {
+		c.insertion = list.New()
+	}
+	e.inserted = c.insertion.PushBack(e)
+}
+
+// Range calls f for each item in the cache in recency order, starting
+// with the least recently used item, until f returns false. Items are
+// not promoted.
+//
+// The function f may remove the item it was called for, for example by
+// calling Remove with its key. It must not otherwise modify the cache,
+// and in particular must not remove any other item, either directly or
+// through the dependencies of an item added by AddWithDeps.
+func (c *Cache[Key, Value]) Range(f func(k Key, v Value) bool) {
+	if c.cache == nil {
+		return
+	}
+	for ele := c.ll.Back(); ele != nil; {
+		prev := ele.Prev()
+		e := ele.Value.(*entry[Key, Value])
+		if !f(e.key, c.copyOut(e.value)) {
+			return
+		}
+		ele = prev
+	}
+}
+
+// RangeInsertionOrder calls f for each item in the cache in the order
+// the items' keys were first added, oldest first, until f returns
+// false. Updating the value of a key does not change its position.
+// Items are not promoted.
+//
+// RangeInsertionOrder only visits items added while
+// TrackInsertionOrder is set. The function f must not modify the
+// cache.
+func (c *Cache[Key, Value]) RangeInsertionOrder(f func(k Key, v Value) bool) {
+	if c.insertion == nil {
+		return
+	}
+	for ele := c.insertion.Front(); ele != nil; ele = ele.Next() {
+		e := ele.Value.(*entry[Key, Value])
+		if !f(e.key, c.copyOut(e.value)) {
+			return
+		}
+	}
+}
+
+// ExportBytes encodes each item in the cache with keyEnc and valEnc
+// and passes the encoded bytes to f, in recency order starting with the
+// least recently used item. It stops at the first error returned by f
+// and returns that error. ExportBytes does not change the recency of
+// any item or generate any Handler events, and f must not modify the
+// cache.
+//
+// ExportBytes allows the cache to be copied to an external store, such
+// as a key-value database, in any serialization format.
+func (c *Cache[Key, Value]) ExportBytes(keyEnc func(Key) []byte, valEnc func(Value) []byte, f func(kb, vb []byte) error) error {
+	if c.cache == nil {
+		return nil
+	}
+	for ele := c.ll.Back(); ele != nil; ele = ele.Prev() {
+		e := ele.Value.(*entry[Key, Value])
+		if err := f(keyEnc(e.key), valEnc(e.value)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// ToMap returns a new map containing all the items in the cache. The
+// returned map is never nil. ToMap does not change the recency of any
+// item or generate any Handler events.
+func (c *Cache[Key, Value]) ToMap() map[Key]Value {
+	m := make(map[Key]Value, len(c.cache))
+	for k, ele := range c.cache {
+		m[k] = c.copyOut(ele.Value.(*entry[Key, Value]).value)
+	}
+	return m
+}
+
+// Keys returns the keys of all the items in the cache, ordered from
+// the least recently used item, which is the next to be evicted, to the
+// most recently used item. The returned slice is never nil. Keys does
+// not change the recency of any item or generate any Handler events.
+func (c *Cache[Key, Value]) Keys() []Key {
+	keys := make([]Key, 0, c.Len())
+	if c.cache == nil {
+		return keys
+	}
+	for ele := c.ll.Back(); ele != nil; ele = ele.Prev() {
+		keys = append(keys, ele.Value.(*entry[Key, Value]).key)
+	}
+	return keys
+}
+
+// Values returns the values of all the items in the cache, in the same
+// order as Keys: from the least recently used item to the most recently
+// used item. The returned slice is never nil. Values does not change the
+// recency of any item or generate any Handler events.
+func (c *Cache[Key, Value]) Values() []Value {
+	values := make([]Value, 0, c.Len())
+	if c.cache == nil {
+		return values
+	}
+	for ele := c.ll.Back(); ele != nil; ele = ele.Prev() {
+		values = append(values, c.copyOut(ele.Value.(*entry[Key, Value]).value))
+	}
+	return values
+}
+
+// Newest returns up to n of the most recently used items in the cache,
+// most recently used first. If n is greater than the number of items,
+// all the items are returned. Newest does not change the recency of
+// any item or generate any Handler events.
+func (c *Cache[Key, Value]) Newest(n int) []struct {
+	K Key
+	V Value
+} {
+	if l := c.Len(); n > l {
+		n = l
+	}
+	if n < 0 {
+		n = 0
+	}
+	newest := make([]struct {
+		K Key
+		V Value
+	}, 0, n)
+	if n == 0 {
+		return newest
+	}
+	for ele := c.ll.Front(); ele != nil && len(newest) < n; ele = ele.Next() {
+		e := ele.Value.(*entry[Key, Value])
+		newest = append(newest, struct {
+			K Key
+			V Value
+		}{e.key, c.copyOut(e.value)})
+	}
+	return newest
+}
+
+// KeySet returns a new set containing the keys of all the items in the
+// cache, for fast membership tests. The returned map is never nil.
+// KeySet does not change the recency of any item or generate any
+// Handler events.
+func (c *Cache[Key, Value]) KeySet() map[Key]struct{} {
+	set := make(map[Key]struct{}, len(c.cache))
+	for k := range c.cache {
+		set[k] = struct{}{}
+	}
+	return set
+}
+
+// GroupBy assigns each item in the cache to a group named by classify
+// and returns the number of items in each group. GroupBy does not
+// change the recency of any item or generate any Handler events.
+func (c *Cache[Key, Value]) GroupBy(classify func(k Key, v Value) string) map[string]int {
+	groups := make(map[string]int)
+	if c.cache == nil {
+		return groups
+	}
+	for ele := c.ll.Back(); ele != nil; ele = ele.Prev() {
+		e := ele.Value.(*entry[Key, Value])
+		groups[classify(e.key, e.value)]++
+	}
+	return groups
+}
+
+// GroupKeysBy is like GroupBy, but returns the keys in each group
+// rather than counting them. The keys in each group are ordered from
+// least to most recently used.
+func (c *Cache[Key, Value]) GroupKeysBy(classify func(k Key, v Value) string) map[string][]Key {
+	groups := make(map[string][]Key)
+	if c.cache == nil {
+		return groups
+	}
+	for ele := c.ll.Back(); ele != nil; ele = ele.Prev() {
+		e := ele.Value.(*entry[Key, Value])
+		g := classify(e.key, e.value)
+		groups[g] = append(groups[g], e.key)
+	}
+	return groups
+}
+
+// EntryOverhead returns an estimate, in bytes, of the fixed memory
+// used to store each item in the cache, not counting any memory the
+// key or value refer to indirectly, such as the contents of a string
+// or slice.
+func (c *Cache[Key, Value]) EntryOverhead() int64 {
+	var k Key
+	var ele list.Element
+	var e entry[Key, Value]
+	var ptr *list.Element
+	// Each item costs a list element, the entry it points to, and a
+	// key/pointer pair in the map, which is assumed to carry about 50%
+	// slack on top of its slots.
+	mapSlot := int64(unsafe.Sizeof(k) + unsafe.Sizeof(ptr))
+	return int64(unsafe.Sizeof(ele)+unsafe.Sizeof(e)) + mapSlot*3/2
+}
+
+// EstimatedBytes returns an estimate of the total memory used by the
+// cache, computed by adding EntryOverhead to the size of each item as
+// measured by sizeOf. The sizeOf function should measure the memory
+// referred to indirectly by the key and value. If sizeOf is nil, only
+// the fixed overhead is counted.
+//
+// EstimatedBytes visits every item in the cache, least recently used
+// first, so its cost is proportional to the number of items.
+func (c *Cache[Key, Value]) EstimatedBytes(sizeOf func(k Key, v Value) int64) int64 {
+	total := int64(c.Len()) * c.EntryOverhead()
+	if sizeOf != nil && c.cache != nil {
+		for ele := c.ll.Back(); ele != nil; ele = ele.Prev() {
+			e := ele.Value.(*entry[Key, Value])
+			total += sizeOf(e.key, e.value)
+		}
+	}
+	return total
+}
+
+// TotalAdded returns the total number of new items added to the cache
+// over its lifetime. Updates to the values of keys already in the
+// cache are not counted. The total is not reset by Clear.
+func (c *Cache[Key, Value]) TotalAdded() uint64 {
+	return c.totalAdded
+}
+
+// TotalRemoved returns the total number of items removed from the
+// cache over its lifetime, whether by the eviction policy, Remove or
+// Clear. The total is not reset by Clear.
+func (c *Cache[Key, Value]) TotalRemoved() uint64 {
+	return c.totalRemoved
+}
+
+// ReplaceAll replaces the entire contents of the cache with entries,
+// without the cache ever being empty or partially filled in between.
+// The entries become the new recency order as if each had been added
+// by Add in turn, so the last entry is the most recently used. If a key
+// occurs more than once, its last value is kept.
+//
+// Each entry is subject to the same checks as Add: an entry whose value
+// exceeds MaxValueSize is skipped, as is a new key beyond
+// MaxDistinctKeys, and CopyOnAdd is applied to each value kept. Each
+// entry kept counts as an add in Stats, and forgets any error
+// remembered for its key by GetOrAddWithErrorCaching. Unlike Add,
+// ReplaceAll does not reject entries under OverflowReject or
+// OverflowError: the eviction policy is applied to the new contents
+// once they are all in place, evicting any surplus.
+//
+// The old items are discarded along with their deadlines, boosts and
+// dependencies, and the new items have none, just as Add clears the
+// deadline of a key added by AddUntil. A key which keeps its place in
+// the cache therefore loses its deadline.
+//
+// If the cache has a Handler, Removed is called for each old item,
+// least recently used first, and then Added is called for each new
+// item, least recently used first. Finally the eviction policy is
+// applied to the new contents.
+//
+// Cache is not safe for concurrent use, so callers which share a Cache
+// between goroutines must hold their lock for the duration of the
+// call.
+func (c *Cache[Key, Value]) ReplaceAll(entries []struct {
+	K Key
+	V Value
+}) {
+	old := c.ll
+	if c.cache == nil {
+		old = nil
+	}
+	if cl, ok := c.Policy.(countLimiter); ok && cl.maxCount() <= 0 {
+		entries = nil
+	}
+	ll := list.New()
+	cache := make(map[Key]*list.Element, len(entries))
+	for _, kv := range entries {
+		k := c.normalize(kv.K)
+		if c.tooLarge(kv.V) {
+			continue
+		}
+		ele, ok := cache[k]
+		if !ok && c.MaxDistinctKeys > 0 && !c.see(k) {
+			continue
+		}
+		v := c.prepare(k, kv.V)
+		c.stats.Adds++
+		if ok {
+			ele.Value.(*entry[Key, Value]).value = v
+			ll.MoveToFront(ele)
+			continue
+		}
+		e := &entry[Key, Value]{key: k, value: v, gen: c.gen}
+		if c.RecordAccessTimes {
+			now := c.now()
+			e.access = &accessTimes{now, now}
+		}
+		cache[k] = ll.PushFront(e)
+		if c.Bloom != nil {
+			c.Bloom.Add(k)
+		}
+	}
+	c.ll, c.cache, c.dependents, c.insertion = ll, cache, nil, nil
+	c.pendingN = 0
+	for ele := ll.Back(); ele != nil; ele = ele.Prev() {
+		c.trackInsertion(ele.Value.(*entry[Key, Value]))
+	}
+	c.totalAdded += uint64(ll.Len())
+	if old != nil {
+		c.totalRemoved += uint64(old.Len())
+		if old.Len() > 0 && ll.Len() == 0 {
+			c.emptied = true
+		}
+	}
+	if old != nil && c.observesRemovals(c.Handler) {
+		c.purge(old, c.Handler)
+	}
+	c.notifyEmpty()
+	if h := c.Handler; h != nil || c.watchers != nil {
+		var zero Value
+		for ele := ll.Back(); ele != nil; ele = ele.Prev() {
+			e := ele.Value.(*entry[Key, Value])
+			if h != nil {
+				h.Added(e.key, zero, e.value, false)
+			}
+			c.notifyWatchers(e.key, e.value)
+		}
+	}
+	c.Evict()
+}
+
+// Reserve prepares the cache to hold n items without growing its
+// internal map, moving the cost of growing the map from the time the
+// cache fills up to the time Reserve is called. This is useful for a
+// cache which will predictably fill up to a known count. If the cache
+// already holds n or more items, or n is zero or negative, Reserve
+// does nothing.
+//
+// Reserve initializes the zero value of Cache, but panics if it is
+// called on an uninitialized Cache with DisableLazyInit set.
+func (c *Cache[Key, Value]) Reserve(n int) {
+	if n <= c.Len() {
+		return
+	}
+	if c.cache == nil {
+		if c.DisableLazyInit {
+			panic("policylru: Reserve called on uninitialized Cache with DisableLazyInit set")
+		}
+		c.ll = list.New()
+	}
+	cache := make(map[Key]*list.Element, n)
+	for k, ele := range c.cache {
+		cache[k] = ele
+	}
+	c.cache = cache
+}
+
+// Len returns the number of items in the cache.
+func (c *Cache[Key, Value]) Len() int {
+	if c.cache == nil {
+		return 0
+	}
+	return c.ll.Len()
+}
+
+// Clear purges all stored items from the cache.
+//
+// If the cache has a Handler, Removed is called for each purged item
+// in recency order, starting with the least recently used item, which
+// is the same order in which the eviction policy would remove them.
+//
+// The cache is emptied before the first call to Removed, so if the
+// Handler adds items to the cache while Clear is running, those items
+// are added to the emptied cache and remain in it after Clear returns.
+func (c *Cache[Key, Value]) Clear() {
+	c.clear(c.Handler)
+}
+
+// ClearSilent purges all stored items from the cache, like Clear, but
+// without generating any Removed events. This is useful when discarding
+// a cache whose Handler does work for each removed item, for example
+// during shutdown. ZeroOnRemove, OnEmpty and key watchers still apply.
+//
+// Because the Handler does not see the removals, a policy which tracks
+// the cache using Handler events, such as MaxSize, is left with stale
+// state, and should be replaced before the cache is used again.
+func (c *Cache[Key, Value]) ClearSilent() {
+	c.clear(nil)
+}
+
+// clear purges all stored items from the cache, generating Removed
+// events on the Handler h if it is not nil.
+func (c *Cache[Key, Value]) clear(h Handler[Key, Value]) {
+	c.failures = nil
+	if c.cache == nil || len(c.cache) == 0 {
+		return
+	}
+	c.totalRemoved += uint64(len(c.cache))
+	c.emptied = true
+	// Clear the map in place so its storage can be reused as the cache
+	// is refilled.
+	for k := range c.cache {
+		delete(c.cache, k)
+	}
+	c.dependents = nil
+	c.insertion = nil
+	c.pendingN = 0
+	if !c.observesRemovals(h) {
+		c.ll.Init()
+		c.notifyEmpty()
+		return
+	}
+	// The handler may add items to the cache, so the old list must stay
+	// intact until all the Removed events have been generated.
+	ll := c.ll
+	c.ll = list.New()
+	c.purge(ll, h)
+	c.notifyEmpty()
+}
+
+// observesRemovals reports whether anything, including the Handler h,
+// needs to know about each item removed from the cache.
+func (c *Cache[Key, Value]) observesRemovals(h Handler[Key, Value]) bool {
+	return h != nil || c.ZeroOnRemove != nil || c.watchers != nil
+}
+
+// purge generates the Removed events for, and scrubs, the entries in
+// the list ll, which has already been detached from the cache, least
+// recently used first. The events go to the Handler h, if it is not
+// nil.
+func (c *Cache[Key, Value]) purge(ll *list.List, h Handler[Key, Value]) {
+	for ele := ll.Back(); ele != nil; ele = ele.Prev() {
+		e := ele.Value.(*entry[Key, Value])
+		if h != nil {
 			h.Removed(e.key, e.value)
 		}
+		c.closeWatchers(e.key)
+		c.scrub(e)
 	}
 }
@@ -13,12 +13,50 @@
 package policylru
 
 import (
+	"container/list"
+	"errors"
+	"math/rand"
+	"strconv"
+	"strings"
 	"testing"
 	"time"
 
 	"github.com/stretchr/testify/assert"
 )
 
+type handlerFuncs[Key, Value any] struct {
+	added   AddedFunc[Key, Value]
+	removed RemovedFunc[Key, Value]
+}
+
+func (h *handlerFuncs[Key, Value]) Added(k Key, old, new Value, update bool) {
+	if h.added != nil {
+		h.added(k, old, new, update)
+	}
+}
+
+func (h *handlerFuncs[Key, Value]) Removed(k Key, v Value) {
+	if h.removed != nil {
+		h.removed(k, v)
+	}
+}
+
+// orderedKeysForTest returns the keys in the cache in recency order,
+// most recently used first. It works on any Cache, including the zero
+// value, for which it returns an empty slice, and never changes the
+// cache, so tests can assert on the exact order produced by a sequence
+// of operations.
+func (c *Cache[Key, Value]) orderedKeysForTest() []Key {
+	keys := []Key{}
+	if c.cache == nil {
+		return keys
+	}
+	for ele := c.ll.Front(); ele != nil; ele = ele.Next() {
+		keys = append(keys, ele.Value.(*entry[Key, Value]).key)
+	}
+	return keys
+}
+
 type simpleStruct struct {
 	int
 	string
@@ -46,6 +84,78 @@ func TestZeroValue(t *testing.T) {
 
 		assert.Equal(t, 0, lru.Len())
 	})
+
+	t.Run("evict", func(t *testing.T) {
+		lru := Cache[int, int]{Policy: MaxCount[int, int](0)}
+
+		n := lru.Evict()
+
+		assert.Equal(t, 0, n)
+	})
+
+	t.Run("disable_lazy_init", func(t *testing.T) {
+		lru := Cache[string, int]{DisableLazyInit: true}
+
+		assert.PanicsWithValue(t, "policylru: Add called on uninitialized Cache with DisableLazyInit set", func() {
+			lru.Add("foo", 1)
+		})
+		assert.Equal(t, 0, lru.Len())
+	})
+}
+
+func TestOrderedKeysForTest(t *testing.T) {
+	t.Run("zero_value", func(t *testing.T) {
+		var lru Cache[string, int]
+
+		assert.Equal(t, []string{}, lru.orderedKeysForTest())
+	})
+
+	t.Run("order", func(t *testing.T) {
+		lru := New[string, int](MaxCount[string, int](3))
+
+		lru.Add("a", 1)
+		lru.Add("b", 2)
+		lru.Add("c", 3)
+		lru.Get("a")
+		lru.Add("b", 20)
+		lru.Add("d", 4)
+		lru.Remove("a")
+
+		assert.Equal(t, []string{"d", "b"}, lru.orderedKeysForTest())
+
+		lru.Clear()
+
+		assert.Equal(t, []string{}, lru.orderedKeysForTest())
+	})
+}
+
+func TestNewTracked(t *testing.T) {
+	p := MaxCountAndSize[string, string](10, 10, func(v string) uint64 {
+		return uint64(len(v))
+	})
+	lru := NewTracked[string, string](p)
+
+	lru.Add("foo", "bar")
+
+	assert.Same(t, p, lru.Policy)
+	assert.Same(t, p, lru.Handler)
+	assert.Equal(t, uint64(3), p.total)
+}
+
+func TestSetPolicyAndHandler(t *testing.T) {
+	var removed []int
+	lru := New[int, int](nil)
+
+	lru.Add(1, 1)
+	lru.Add(2, 2)
+	lru.SetHandler(RemovedFunc[int, int](func(k, _ int) {
+		removed = append(removed, k)
+	}))
+	lru.SetPolicy(MaxCount[int, int](1))
+	lru.Evict()
+
+	assert.Equal(t, []int{1}, removed)
+	assert.Equal(t, MaxCount[int, int](1), lru.Policy)
 }
 
 func TestAddAndGet(t *testing.T) {
@@ -181,110 +291,2363 @@ func TestAddAndGet(t *testing.T) {
 	})
 }
 
-func TestRemove(t *testing.T) {
-	t.Run("removed", func(t *testing.T) {
+type promotionRecorder struct {
+	RemovedFunc[string, int]
+	promotions []int
+}
+
+func (r *promotionRecorder) Promoted(_ string, fromRank, toRank int) {
+	r.promotions = append(r.promotions, fromRank, toRank)
+}
+
+func TestBounded(t *testing.T) {
+	pred := func(string, int) bool { return false }
+
+	assert.False(t, New[string, int](nil).Bounded())
+	assert.True(t, New[string, int](MaxCount[string, int](1)).Bounded())
+	assert.False(t, New[string, int](EvictPreferring[string, int](pred, nil)).Bounded())
+	assert.True(t, New[string, int](EvictPreferring[string, int](pred, MaxCount[string, int](1))).Bounded())
+}
+
+func TestPromoted(t *testing.T) {
+	t.Run("without_ranks", func(t *testing.T) {
+		r := &promotionRecorder{RemovedFunc: func(string, int) {}}
+		lru := NewWithHandler[string, int](nil, r)
+
+		lru.Add("a", 1)
+		lru.Add("b", 2)
+		lru.Get("b")
+		lru.Get("a")
+		lru.Get("c")
+
+		assert.Equal(t, []int{-1, 0}, r.promotions)
+	})
+
+	t.Run("with_ranks", func(t *testing.T) {
+		r := &promotionRecorder{RemovedFunc: func(string, int) {}}
+		lru := NewWithHandler[string, int](nil, r)
+		lru.RankPromotions = true
+
+		lru.Add("a", 1)
+		lru.Add("b", 2)
+		lru.Add("c", 3)
+		lru.Get("a")
+		lru.Get("a")
+		lru.Get("c")
+
+		assert.Equal(t, []int{2, 0, 1, 0}, r.promotions)
+	})
+}
+
+func TestTouchAll(t *testing.T) {
+	lru := New[string, int](MaxCount[string, int](3))
+	lru.Add("a", 1)
+	lru.Add("b", 2)
+	lru.Add("c", 3)
+
+	n := lru.TouchAll("b", "x", "a")
+	lru.Add("d", 4)
+	_, okC := lru.Get("c")
+
+	assert.Equal(t, 2, n)
+	assert.False(t, okC)
+	assert.Equal(t, []string{"b", "a", "d"}, lru.SurvivingKeys())
+}
+
+func TestKeyNormalizer(t *testing.T) {
+	var added, removed []string
+	lru := NewWithHandler[string, int](nil, &handlerFuncs[string, int]{
+		added: func(k string, _, _ int, _ bool) {
+			added = append(added, k)
+		},
+		removed: func(k string, _ int) {
+			removed = append(removed, k)
+		},
+	})
+	lru.KeyNormalizer = strings.ToLower
+
+	lru.Add("Foo", 1)
+	lru.Add("FOO", 2)
+	value, ok := lru.Get("foo")
+	shouldBeTrue := lru.Remove("fOO")
+
+	assert.True(t, ok)
+	assert.Equal(t, 2, value)
+	assert.True(t, shouldBeTrue)
+	assert.Equal(t, 0, lru.Len())
+	assert.Equal(t, []string{"foo", "foo"}, added)
+	assert.Equal(t, []string{"foo"}, removed)
+}
+
+func TestMaxDistinctKeys(t *testing.T) {
+	lru := New[string, int](MaxCount[string, int](1))
+	lru.MaxDistinctKeys = 2
+
+	lru.Add("a", 1)
+	lru.Add("b", 2)
+	lru.Add("c", 3)
+	_, okC := lru.Get("c")
+	lru.Add("a", 10)
+	valueA, okA := lru.Get("a")
+
+	assert.False(t, okC)
+	assert.True(t, okA)
+	assert.Equal(t, 10, valueA)
+	assert.Equal(t, 2, lru.DistinctKeys())
+	assert.False(t, lru.AdmitNewKeys())
+
+	lru.ResetDistinctKeys()
+	lru.Add("c", 3)
+	valueC, okC := lru.Get("c")
+
+	assert.True(t, okC)
+	assert.Equal(t, 3, valueC)
+	assert.Equal(t, 1, lru.DistinctKeys())
+	assert.True(t, lru.AdmitNewKeys())
+}
+
+func TestCopyOnGet(t *testing.T) {
+	lru := New[string, []int](nil)
+	lru.CopyOnGet = func(v []int) []int {
+		return append([]int(nil), v...)
+	}
+
+	lru.Add("a", []int{1, 2, 3})
+	v1, _ := lru.Get("a")
+	v1[0] = 100
+	v2, _, _ := lru.GetWithStale("a")
+	v2[1] = 200
+	v3, _ := lru.Get("a")
+
+	assert.Equal(t, []int{1, 2, 3}, v3)
+
+	t.Run("bulk_methods", func(t *testing.T) {
+		lru := New[string, []int](MaxCount[string, []int](1))
+		lru.CopyOnGet = func(v []int) []int {
+			return append([]int(nil), v...)
+		}
+		lru.TrackInsertionOrder = true
+		lru.TwoPhaseEviction = true
+		lru.Add("a", []int{1})
+		lru.Add("b", []int{2})
+
+		lru.Range(func(_ string, v []int) bool {
+			v[0] = 100
+			return true
+		})
+		lru.RangeInsertionOrder(func(_ string, v []int) bool {
+			v[0] = 100
+			return true
+		})
+		lru.ToMap()["b"][0] = 100
+		lru.Values()[1][0] = 100
+		lru.Newest(1)[0].V[0] = 100
+		lru.PendingEvictions()[0].V[0] = 100
+
+		assert.Equal(t, map[string][]int{"a": {1}, "b": {2}}, lru.ToMap())
+	})
+}
+
+func TestCopyOnAdd(t *testing.T) {
+	var added []int
+	lru := NewWithHandler[string, []int](nil, AddedFunc[string, []int](func(_ string, _, new []int, _ bool) {
+		added = new
+	}))
+	lru.CopyOnAdd = func(v []int) []int {
+		return append([]int(nil), v...)
+	}
+
+	v := []int{1, 2, 3}
+	lru.Add("a", v)
+	v[0] = 100
+	stored, _ := lru.Get("a")
+
+	assert.Equal(t, []int{1, 2, 3}, stored)
+	assert.Equal(t, []int{1, 2, 3}, added)
+}
+
+func TestGetOrDefault(t *testing.T) {
+	t.Run("no_supplier", func(t *testing.T) {
 		lru := New[string, int](nil)
+		lru.Add("a", 1)
 
-		lru.Add("foo", 1001)
-		removed := lru.Remove("foo")
+		assert.Equal(t, 1, lru.GetOrDefault("a"))
+		assert.Equal(t, 0, lru.GetOrDefault("b"))
+	})
 
-		assert.True(t, removed)
-		assert.Equal(t, 0, lru.Len())
+	t.Run("supplier", func(t *testing.T) {
+		var calls int
+		lru := New[string, int](nil)
+		lru.DefaultValue = func(k string) int {
+			calls++
+			return len(k)
+		}
+		lru.Add("a", 1)
+
+		assert.Equal(t, 1, lru.GetOrDefault("a"))
+		assert.Equal(t, 3, lru.GetOrDefault("bcd"))
+		assert.Equal(t, 3, lru.GetOrDefault("bcd"))
+		assert.Equal(t, 2, calls)
+		assert.Equal(t, 1, lru.Len())
 	})
+}
 
-	t.Run("not_removed", func(t *testing.T) {
-		lru := New[int, int](nil)
+func TestGetOrAdd(t *testing.T) {
+	var calls int
+	f := func() int {
+		calls++
+		return calls * 10
+	}
+	lru := New[string, int](MaxCount[string, int](2))
+	lru.Add("a", 1)
+	lru.Add("b", 2)
 
-		removed := lru.Remove(0)
+	valueA, loadedA := lru.GetOrAdd("a", f)
+	valueC, loadedC := lru.GetOrAdd("c", f)
+	valueC2, loadedC2 := lru.GetOrAdd("c", f)
 
-		assert.False(t, removed)
+	assert.True(t, loadedA)
+	assert.Equal(t, 1, valueA)
+	assert.False(t, loadedC)
+	assert.Equal(t, 10, valueC)
+	assert.True(t, loadedC2)
+	assert.Equal(t, 10, valueC2)
+	assert.Equal(t, 1, calls)
+	assert.Equal(t, []string{"c", "a"}, lru.orderedKeysForTest())
+
+	t.Run("single_lookup", func(t *testing.T) {
+		var normalized int
+		lru := New[string, int](nil)
+		lru.KeyNormalizer = func(k string) string {
+			normalized++
+			return strings.ToLower(k)
+		}
+
+		value, loaded := lru.GetOrAdd("A", func() int { return 1 })
+		stats := lru.Stats()
+
+		assert.False(t, loaded)
+		assert.Equal(t, 1, value)
+		assert.Equal(t, 1, normalized)
+		assert.Equal(t, uint64(1), stats.Misses)
+		assert.Equal(t, []string{"a"}, lru.orderedKeysForTest())
+	})
+
+	t.Run("reentrant", func(t *testing.T) {
+		lru := New[string, int](nil)
+
+		value, loaded := lru.GetOrAdd("a", func() int {
+			lru.Add("a", 1)
+			return 2
+		})
+		stored, _ := lru.Peek("a")
+
+		assert.False(t, loaded)
+		assert.Equal(t, 2, value)
+		assert.Equal(t, 2, stored)
+		assert.Equal(t, 1, lru.Len())
+		assert.Equal(t, []string{"a"}, lru.orderedKeysForTest())
+	})
+}
+
+func TestGetOrAddWithErrorCaching(t *testing.T) {
+	errLoad := errors.New("load failed")
+	var calls int
+	fail := func() (int, error) {
+		calls++
+		return 0, errLoad
+	}
+	succeed := func() (int, error) {
+		calls++
+		return calls, nil
+	}
+
+	t.Run("caches_error", func(t *testing.T) {
+		calls = 0
+		lru := New[string, int](nil)
+
+		_, err1 := lru.GetOrAddWithErrorCaching("a", fail, time.Hour)
+		_, err2 := lru.GetOrAddWithErrorCaching("a", succeed, time.Hour)
+
+		assert.ErrorIs(t, err1, errLoad)
+		assert.ErrorIs(t, err2, errLoad)
+		assert.Equal(t, 1, calls)
 		assert.Equal(t, 0, lru.Len())
+
+		lru.Remove("a")
+		value, err3 := lru.GetOrAddWithErrorCaching("a", succeed, time.Hour)
+		value2, err4 := lru.GetOrAddWithErrorCaching("a", fail, time.Hour)
+
+		assert.NoError(t, err3)
+		assert.Equal(t, 2, value)
+		assert.NoError(t, err4)
+		assert.Equal(t, 2, value2)
+		assert.Equal(t, 2, calls)
 	})
 
-	t.Run("with_removed_handler", func(t *testing.T) {
-		var removedKey int
-		var removedValue string
-		var removedTime time.Time
-		lru := NewWithHandler[int, string](nil, RemovedFunc[int, string](func(k int, v string) {
-			removedKey = k
-			removedValue = v
-			removedTime = time.Now()
+	t.Run("retries_after_ttl", func(t *testing.T) {
+		calls = 0
+		lru := New[string, int](nil)
+
+		now := time.Unix(1000, 0)
+		lru.Clock = func() time.Time { return now }
+
+		_, err1 := lru.GetOrAddWithErrorCaching("a", fail, time.Second)
+		now = now.Add(time.Second)
+		value, err2 := lru.GetOrAddWithErrorCaching("a", succeed, time.Second)
+
+		assert.ErrorIs(t, err1, errLoad)
+		assert.NoError(t, err2)
+		assert.Equal(t, 2, value)
+	})
+
+	t.Run("add_and_clear_forget", func(t *testing.T) {
+		calls = 0
+		lru := New[string, int](nil)
+
+		_, _ = lru.GetOrAddWithErrorCaching("a", fail, time.Hour)
+		_, _ = lru.GetOrAddWithErrorCaching("b", fail, time.Hour)
+		lru.Add("a", 10)
+		lru.Clear()
+		_, errA := lru.GetOrAddWithErrorCaching("a", succeed, time.Hour)
+		_, errB := lru.GetOrAddWithErrorCaching("b", succeed, time.Hour)
+
+		assert.NoError(t, errA)
+		assert.NoError(t, errB)
+		assert.Equal(t, 4, calls)
+	})
+
+	t.Run("expired_swept", func(t *testing.T) {
+		lru := New[string, int](nil)
+
+		now := time.Unix(1000, 0)
+		lru.Clock = func() time.Time { return now }
+
+		for i := 0; i < 100; i++ {
+			_, _ = lru.GetOrAddWithErrorCaching(strconv.Itoa(i), fail, time.Second)
+			now = now.Add(time.Second)
+		}
+
+		assert.Len(t, lru.failures, 1)
+	})
+}
+
+func TestGetWithStale(t *testing.T) {
+	lru := New[string, int](nil)
+
+	lru.Add("a", 1)
+	lru.Add("b", 2)
+	_, stale0, _ := lru.GetWithStale("a")
+	lru.BumpGeneration()
+	lru.Add("b", 20)
+	lru.Add("c", 3)
+	valueA, staleA, okA := lru.GetWithStale("a")
+	valueB, staleB, okB := lru.GetWithStale("b")
+	_, staleC, _ := lru.GetWithStale("c")
+	_, staleD, okD := lru.GetWithStale("d")
+
+	assert.False(t, stale0)
+	assert.True(t, okA)
+	assert.Equal(t, 1, valueA)
+	assert.True(t, staleA)
+	assert.True(t, okB)
+	assert.Equal(t, 20, valueB)
+	assert.False(t, staleB)
+	assert.False(t, staleC)
+	assert.False(t, okD)
+	assert.False(t, staleD)
+}
+
+func TestAddBatch(t *testing.T) {
+	t.Run("evicts_once", func(t *testing.T) {
+		var evictCalls int
+		var added []string
+		lru := NewWithHandler[string, int](PolicyFunc[string, int](func(_ string, _ int, n int) bool {
+			evictCalls++
+			return n > 2
+		}), AddedFunc[string, int](func(k string, _, _ int, _ bool) {
+			added = append(added, k)
 		}))
 
-		lru.Add(10, "lorem")
-		lru.Add(15, "ipsum")
-		before := time.Now()
-		shouldBeTrue := lru.Remove(15)
-		after := time.Now()
-		shouldBeFalse := lru.Remove(15)
+		lru.AddBatch([]struct {
+			K string
+			V int
+		}{{"a", 1}, {"b", 2}, {"c", 3}, {"a", 10}, {"d", 4}})
 
-		assert.True(t, shouldBeTrue)
-		assert.Equal(t, 15, removedKey)
-		assert.Equal(t, "ipsum", removedValue)
-		assert.False(t, removedTime.Before(before))
-		assert.False(t, after.Before(removedTime))
-		assert.Equal(t, 1, lru.Len())
-		assert.False(t, shouldBeFalse)
+		assert.Equal(t, []string{"a", "b", "c", "a", "d"}, added)
+		assert.Equal(t, []string{"d", "a"}, lru.orderedKeysForTest())
+		assert.Equal(t, 3, evictCalls)
+	})
+	t.Run("zero_value", func(t *testing.T) {
+		var lru Cache[string, int]
+
+		lru.AddBatch(nil)
+		lru.AddBatch([]struct {
+			K string
+			V int
+		}{{"a", 1}})
+
+		assert.Equal(t, []string{"a"}, lru.orderedKeysForTest())
+	})
+	t.Run("overflow_reject", func(t *testing.T) {
+		lru := New[string, int](MaxCount[string, int](2))
+		lru.Overflow = OverflowReject
+
+		lru.AddBatch([]struct {
+			K string
+			V int
+		}{{"a", 1}, {"b", 2}, {"c", 3}, {"b", 20}})
+		value, _ := lru.Get("b")
+
+		assert.Equal(t, []string{"b", "a"}, lru.orderedKeysForTest())
+		assert.Equal(t, 20, value)
 	})
 }
 
-func TestEvict(t *testing.T) {
-	t.Run("implicit_during_add", func(t *testing.T) {
-		lru := New[int, int](MaxCount[int, int](2))
+func TestAddUntil(t *testing.T) {
+	t.Run("expiry", func(t *testing.T) {
+		var removed []string
+		lru := NewWithHandler[string, int](MaxCount[string, int](3), &handlerFuncs[string, int]{
+			removed: func(k string, _ int) { removed = append(removed, k) },
+		})
 
-		lru.Add(1, 11)
-		lru.Add(2, 22)
-		lru.Add(1, 11)
-		lru.Add(3, 33)
-		value, ok := lru.Get(2)
+		lru.Add("a", 1)
+		lru.AddUntil("b", 2, time.Now().Add(-time.Second))
+		lru.AddUntil("c", 3, time.Now().Add(time.Hour))
+		_, okA := lru.Get("a")
+		_, okB := lru.Get("b")
+		valueC, okC := lru.Get("c")
 
+		assert.True(t, okA)
+		assert.False(t, okB)
+		assert.True(t, okC)
+		assert.Equal(t, 3, valueC)
+		assert.Equal(t, []string{"b"}, removed)
 		assert.Equal(t, 2, lru.Len())
-		assert.Equal(t, 0, value)
+	})
+
+	t.Run("add_clears_deadline", func(t *testing.T) {
+		lru := New[string, int](nil)
+
+		lru.AddUntil("a", 1, time.Now().Add(-time.Second))
+		lru.Add("a", 10)
+		value, ok := lru.Get("a")
+
+		assert.True(t, ok)
+		assert.Equal(t, 10, value)
+	})
+
+	t.Run("add_until_replaces_deadline", func(t *testing.T) {
+		lru := New[string, int](nil)
+
+		lru.Add("a", 1)
+		lru.AddUntil("a", 10, time.Now().Add(-time.Second))
+		_, _, ok := lru.GetWithStale("a")
+
 		assert.False(t, ok)
+		assert.Equal(t, 0, lru.Len())
 	})
+}
 
-	t.Run("explicit", func(t *testing.T) {
-		maxSize := 10
-		policy := PolicyFunc[string, string](func(_, _ string, n int) bool {
-			return n > maxSize
+func TestValidator(t *testing.T) {
+	var removed []string
+	lru := NewWithHandler[string, int](nil, &handlerFuncs[string, int]{
+		removed: func(k string, _ int) { removed = append(removed, k) },
+	})
+	lru.Validator = func(_ string, v int) bool { return v >= 0 }
+
+	lru.Add("a", 1)
+	lru.Add("b", -1)
+	valueA, okA := lru.Get("a")
+	_, okB := lru.Get("b")
+	_, _, okB2 := lru.GetWithStale("b")
+
+	assert.True(t, okA)
+	assert.Equal(t, 1, valueA)
+	assert.False(t, okB)
+	assert.False(t, okB2)
+	assert.Equal(t, []string{"b"}, removed)
+	assert.Equal(t, 1, lru.Len())
+}
+
+func TestZeroOnRemove(t *testing.T) {
+	zero := func(v *[]byte) {
+		for i := range *v {
+			(*v)[i] = 0
+		}
+		*v = nil
+	}
+
+	for _, reverse := range []bool{false, true} {
+		t.Run("reverse_"+strconv.FormatBool(reverse), func(t *testing.T) {
+			var seen []string
+			lru := NewWithHandler[string, []byte](MaxCount[string, []byte](1), &handlerFuncs[string, []byte]{
+				removed: func(_ string, v []byte) { seen = append(seen, string(v)) },
+			})
+			lru.ReverseEvictionEvents = reverse
+			lru.ZeroOnRemove = zero
+			a, b, c := []byte("a"), []byte("b"), []byte("c")
+
+			lru.Add("a", a)
+			lru.Add("b", b)
+			lru.Add("c", c)
+			lru.Remove("b")
+
+			assert.Equal(t, []string{"a", "b"}, seen)
+			assert.Equal(t, []byte{0}, a)
+			assert.Equal(t, []byte{0}, b)
+			assert.Equal(t, []byte("c"), c)
+
+			lru.Clear()
+
+			assert.Equal(t, []string{"a", "b", "c"}, seen)
+			assert.Equal(t, []byte{0}, c)
 		})
-		lru := New[string, string](policy)
+	}
 
-		lru.Add("doomed", "to eviction")
-		lru.Add("ill-fated", "due to being an eviction target")
-		lru.Add("lucky", "to survive")
-		lru.Add("blessed", "to avoid the evict-pocalypse")
+	t.Run("no_handler", func(t *testing.T) {
+		lru := New[string, []byte](nil)
+		lru.ZeroOnRemove = zero
+		a, b := []byte("a"), []byte("b")
 
-		assert.Equal(t, 4, lru.Len())
+		lru.Add("a", a)
+		lru.ReplaceAll([]struct {
+			K string
+			V []byte
+		}{{"b", b}})
 
-		maxSize = 2
-		lru.Evict()
-		_, ok1 := lru.Get("ill-fated")
-		value2, ok2 := lru.Get("lucky")
-		value3, ok3 := lru.Get("blessed")
+		assert.Equal(t, []byte{0}, a)
 
-		assert.Equal(t, 2, lru.Len())
-		assert.False(t, ok1)
-		assert.True(t, ok2)
-		assert.Equal(t, "to survive", value2)
-		assert.True(t, ok3)
-		assert.Equal(t, "to avoid the evict-pocalypse", value3)
+		lru.Clear()
+
+		assert.Equal(t, []byte{0}, b)
 	})
 }
 
-func TestClear(t *testing.T) {
+func TestFallback(t *testing.T) {
+	l2 := NewArrayCache[string, int](4)
+	l2.Add("b", 2)
+	l2.Add("c", 3)
+	l1 := New[string, int](MaxCount[string, int](1))
+	l1.Fallback = l2
+	l1.Add("a", 1)
+
+	valueA, okA := l1.Get("a")
+	valueB, okB := l1.Get("b")
+	_, staleC, okC := l1.GetWithStale("c")
+	_, okD := l1.Get("d")
+	_, okA2 := l2.Get("a")
+
+	assert.True(t, okA)
+	assert.Equal(t, 1, valueA)
+	assert.True(t, okB)
+	assert.Equal(t, 2, valueB)
+	assert.True(t, okC)
+	assert.False(t, staleC)
+	assert.False(t, okD)
+	assert.Equal(t, []string{"c"}, l1.orderedKeysForTest())
+	assert.False(t, okA2)
+
+	t.Run("counts_miss", func(t *testing.T) {
+		var events []string
+		l2 := NewArrayCache[string, int](4)
+		l2.Add("b", 2)
+		l1 := NewTracked[string, int](&eventPolicyForTest{events: &events})
+		l1.Fallback = l2
+
+		value, ok := l1.Get("b")
+		stats := l1.Stats()
+
+		assert.True(t, ok)
+		assert.Equal(t, 2, value)
+		assert.Equal(t, uint64(0), stats.Hits)
+		assert.Equal(t, uint64(1), stats.Misses)
+		assert.Equal(t, []string{"lookup b false"}, events)
+	})
+}
+
+func TestRemoveMulti(t *testing.T) {
+	var removed []string
+	lru := NewWithHandler[string, int](nil, RemovedFunc[string, int](func(k string, _ int) {
+		removed = append(removed, k)
+	}))
+	lru.Add("a", 1)
+	lru.Add("b", 2)
+	lru.Add("c", 3)
+	lru.AddWithDeps("d", 4, []string{"c"})
+
+	n := lru.RemoveMulti([]string{"c", "x", "a", "c"})
+
+	assert.Equal(t, 2, n)
+	assert.Equal(t, []string{"c", "d", "a"}, removed)
+	assert.Equal(t, []string{"b"}, lru.orderedKeysForTest())
+}
+
+func TestRemoveOldest(t *testing.T) {
+	t.Run("zero_value", func(t *testing.T) {
+		var lru Cache[string, int]
+
+		k, v, ok := lru.RemoveOldest()
+
+		assert.False(t, ok)
+		assert.Equal(t, "", k)
+		assert.Equal(t, 0, v)
+	})
+	t.Run("oldest_first", func(t *testing.T) {
+		var removed []string
+		lru := NewWithHandler[string, int](nil, RemovedFunc[string, int](func(k string, _ int) {
+			removed = append(removed, k)
+		}))
+		lru.Add("a", 1)
+		lru.Add("b", 2)
+		lru.Get("a")
+
+		k1, v1, ok1 := lru.RemoveOldest()
+		k2, v2, ok2 := lru.RemoveOldest()
+		_, _, ok3 := lru.RemoveOldest()
+
+		assert.True(t, ok1)
+		assert.Equal(t, "b", k1)
+		assert.Equal(t, 2, v1)
+		assert.True(t, ok2)
+		assert.Equal(t, "a", k2)
+		assert.Equal(t, 1, v2)
+		assert.False(t, ok3)
+		assert.Equal(t, 0, lru.Len())
+		assert.Equal(t, []string{"b", "a"}, removed)
+	})
+	t.Run("zero_on_remove", func(t *testing.T) {
+		var scrubbed int
+		lru := New[string, []byte](nil)
+		lru.ZeroOnRemove = func(v *[]byte) {
+			scrubbed++
+			*v = nil
+		}
+		lru.Add("a", []byte("xyz"))
+
+		k, v, ok := lru.RemoveOldest()
+
+		assert.True(t, ok)
+		assert.Equal(t, "a", k)
+		assert.Equal(t, []byte("xyz"), v)
+		assert.Equal(t, 1, scrubbed)
+	})
+}
+
+func TestGetOldest(t *testing.T) {
+	t.Run("zero_value", func(t *testing.T) {
+		var lru Cache[string, int]
+
+		_, _, ok := lru.GetOldest()
+
+		assert.False(t, ok)
+	})
+	t.Run("no_promotion", func(t *testing.T) {
+		lru := New[string, int](nil)
+		lru.Add("a", 1)
+		lru.Add("b", 2)
+		lru.Get("a")
+
+		k, v, ok := lru.GetOldest()
+		k2, _, _ := lru.GetOldest()
+
+		assert.True(t, ok)
+		assert.Equal(t, "b", k)
+		assert.Equal(t, 2, v)
+		assert.Equal(t, "b", k2)
+		assert.Equal(t, []string{"a", "b"}, lru.orderedKeysForTest())
+	})
+	t.Run("empty", func(t *testing.T) {
+		lru := New[string, int](nil)
+		lru.Add("a", 1)
+		lru.Remove("a")
+
+		_, _, ok := lru.GetOldest()
+
+		assert.False(t, ok)
+	})
+}
+
+func TestPeek(t *testing.T) {
+	t.Run("zero_value", func(t *testing.T) {
+		var lru Cache[string, int]
+
+		value, ok := lru.Peek("a")
+
+		assert.False(t, ok)
+		assert.Equal(t, 0, value)
+	})
+	t.Run("no_promotion", func(t *testing.T) {
+		var added []string
+		now := time.Unix(1000, 0)
+		lru := NewWithHandler[string, int](nil, AddedFunc[string, int](func(k string, _, _ int, _ bool) {
+			added = append(added, k)
+		}))
+		lru.Clock = func() time.Time { return now }
+		lru.Add("a", 1)
+		lru.Add("b", 2)
+		lru.AddUntil("c", 3, now)
+
+		valueA, okA := lru.Peek("a")
+		valueC, okC := lru.Peek("c")
+		valueX, okX := lru.Peek("x")
+
+		assert.True(t, okA)
+		assert.Equal(t, 1, valueA)
+		assert.False(t, okC)
+		assert.Equal(t, 0, valueC)
+		assert.False(t, okX)
+		assert.Equal(t, 0, valueX)
+		assert.Equal(t, []string{"c", "b", "a"}, lru.orderedKeysForTest())
+		assert.Equal(t, []string{"a", "b", "c"}, added)
+	})
+}
+
+func TestPeekAll(t *testing.T) {
+	now := time.Unix(1000, 0)
+	lru := New[string, int](MaxCount[string, int](3))
+	lru.Clock = func() time.Time { return now }
+	lru.Add("a", 1)
+	lru.Add("b", 2)
+	lru.AddUntil("c", 3, now)
+
+	values, misses := lru.PeekAll([]string{"a", "x", "c", "b"})
+
+	assert.Equal(t, map[string]int{"a": 1, "b": 2}, values)
+	assert.Equal(t, []string{"x", "c"}, misses)
+	assert.Equal(t, []string{"c", "b", "a"}, lru.orderedKeysForTest())
+}
+
+func TestGetMulti(t *testing.T) {
+	lru := New[string, int](nil)
+	lru.Add("a", 1)
+	lru.Add("b", 2)
+	lru.Add("c", 3)
+
+	found, missing := lru.GetMulti([]string{"b", "x", "a", "y"})
+
+	assert.Equal(t, map[string]int{"a": 1, "b": 2}, found)
+	assert.Equal(t, []string{"x", "y"}, missing)
+	assert.Equal(t, []string{"a", "b", "c"}, lru.orderedKeysForTest())
+	assert.Equal(t, Stats{Hits: 2, Misses: 2, Adds: 3}, lru.Stats())
+}
+
+func TestAccessInfo(t *testing.T) {
+	t.Run("disabled", func(t *testing.T) {
+		lru := New[string, int](nil)
+		lru.Add("a", 1)
+
+		_, _, ok := lru.AccessInfo("a")
+
+		assert.False(t, ok)
+	})
+
+	t.Run("enabled", func(t *testing.T) {
+		now := time.Unix(1000, 0)
+		lru := New[string, int](nil)
+		lru.RecordAccessTimes = true
+		lru.Clock = func() time.Time { return now }
+
+		lru.Add("a", 1)
+		lru.Add("b", 2)
+		now = now.Add(time.Second)
+		lru.Get("a")
+		now = now.Add(time.Second)
+		lru.Add("b", 20)
+		now = now.Add(time.Second)
+		lru.Get("x")
+		firstA, lastA, okA := lru.AccessInfo("a")
+		firstB, lastB, okB := lru.AccessInfo("b")
+		_, _, okX := lru.AccessInfo("x")
+
+		assert.True(t, okA)
+		assert.Equal(t, time.Unix(1000, 0), firstA)
+		assert.Equal(t, time.Unix(1001, 0), lastA)
+		assert.True(t, okB)
+		assert.Equal(t, time.Unix(1000, 0), firstB)
+		assert.Equal(t, time.Unix(1002, 0), lastB)
+		assert.False(t, okX)
+	})
+
+	t.Run("clock_drives_deadlines", func(t *testing.T) {
+		now := time.Unix(1000, 0)
+		lru := New[string, int](nil)
+		lru.Clock = func() time.Time { return now }
+
+		lru.AddUntil("a", 1, now.Add(time.Minute))
+		_, ok1 := lru.Get("a")
+		now = now.Add(time.Minute)
+		_, ok2 := lru.Get("a")
+
+		assert.True(t, ok1)
+		assert.False(t, ok2)
+	})
+}
+
+func TestOverflow(t *testing.T) {
+	t.Run("evict", func(t *testing.T) {
+		lru := New[string, int](MaxCount[string, int](1))
+
+		err1 := lru.AddOrErr("a", 1)
+		err2 := lru.AddOrErr("b", 2)
+		_, ok := lru.Get("b")
+
+		assert.NoError(t, err1)
+		assert.NoError(t, err2)
+		assert.True(t, ok)
+	})
+
+	t.Run("reject", func(t *testing.T) {
+		var events []string
+		lru := NewWithHandler[string, int](MaxCount[string, int](1), &handlerFuncs[string, int]{
+			added:   func(k string, _, _ int, _ bool) { events = append(events, "+"+k) },
+			removed: func(k string, _ int) { events = append(events, "-"+k) },
+		})
+		lru.Overflow = OverflowReject
+
+		lru.Add("a", 1)
+		err := lru.AddOrErr("b", 2)
+		lru.Add("a", 10)
+		value, ok := lru.Get("a")
+
+		assert.NoError(t, err)
+		assert.True(t, ok)
+		assert.Equal(t, 10, value)
+		assert.Equal(t, 1, lru.Len())
+		assert.Equal(t, []string{"+a", "+a"}, events)
+	})
+
+	t.Run("reject_leaves_no_trace", func(t *testing.T) {
+		var zeroed, emptied int
+		lru := New[string, []byte](MaxCount[string, []byte](1))
+		lru.Overflow = OverflowError
+		lru.ZeroOnRemove = func(*[]byte) { zeroed++ }
+		lru.OnEmpty = func() { emptied++ }
+		lru.Bloom = NewBloomFilter[string](1000, 7, fnvHash)
+		lru.Add("a", []byte("a"))
+		buf := []byte("bb")
+
+		err := lru.AddOrErr("b", buf)
+
+		assert.ErrorIs(t, err, ErrCacheFull)
+		assert.Equal(t, []byte("bb"), buf)
+		assert.Equal(t, 0, zeroed)
+		assert.Equal(t, 0, emptied)
+		assert.Equal(t, uint64(1), lru.Stats().Adds)
+		assert.Equal(t, uint64(1), lru.TotalAdded())
+		assert.Equal(t, uint64(0), lru.TotalRemoved())
+		assert.True(t, lru.DefinitelyAbsent("b"))
+	})
+
+	t.Run("reject_from_empty", func(t *testing.T) {
+		var emptied int
+		p := MaxSize[string, string](4, func(v string) uint64 { return uint64(len(v)) })
+		lru := NewTracked[string, string](p)
+		lru.Overflow = OverflowError
+		lru.OnEmpty = func() { emptied++ }
+
+		err := lru.AddOrErr("a", "12345")
+
+		assert.ErrorIs(t, err, ErrCacheFull)
+		assert.Equal(t, 0, emptied)
+		assert.Equal(t, 0, lru.Len())
+	})
+
+	t.Run("reject_keeps_distinct_keys", func(t *testing.T) {
+		p := MaxSize[string, string](4, func(v string) uint64 { return uint64(len(v)) })
+		lru := NewTracked[string, string](p)
+		lru.Overflow = OverflowReject
+		lru.MaxDistinctKeys = 1
+
+		lru.Add("a", "12345")
+		lru.Add("b", "12")
+
+		assert.Equal(t, 1, lru.DistinctKeys())
+		assert.Equal(t, []string{"b"}, lru.orderedKeysForTest())
+	})
+
+	t.Run("error", func(t *testing.T) {
+		lru := New[string, int](MaxCount[string, int](2))
+		lru.Overflow = OverflowError
+
+		err1 := lru.AddOrErr("a", 1)
+		err2 := lru.AddOrErr("b", 2)
+		err3 := lru.AddOrErr("c", 3)
+		err4 := lru.AddOrErr("a", 4)
+		_, ok := lru.Get("c")
+
+		assert.NoError(t, err1)
+		assert.NoError(t, err2)
+		assert.ErrorIs(t, err3, ErrCacheFull)
+		assert.NoError(t, err4)
+		assert.False(t, ok)
+		assert.Equal(t, 2, lru.Len())
+	})
+
+	t.Run("selector", func(t *testing.T) {
+		p := Partitioned[string, int](func(k string) byte { return k[0] }, func(byte) int { return 1 })
+		lru := NewTracked[string, int](p)
+		lru.Overflow = OverflowError
+
+		err1 := lru.AddOrErr("a1", 1)
+		err2 := lru.AddOrErr("b1", 1)
+		err3 := lru.AddOrErr("a2", 2)
+
+		assert.NoError(t, err1)
+		assert.NoError(t, err2)
+		assert.ErrorIs(t, err3, ErrCacheFull)
+		assert.Equal(t, 2, lru.Len())
+	})
+}
+
+func TestMaxValueSize(t *testing.T) {
+	lru := New[string, string](nil)
+	lru.MaxValueSize = 3
+	lru.ValueSize = func(v string) int64 { return int64(len(v)) }
+
+	err1 := lru.AddOrErr("a", "abc")
+	err2 := lru.AddOrErr("b", "abcd")
+	lru.Add("a", "abcd")
+	lru.AddUntil("c", "abcd", time.Now().Add(time.Hour))
+	lru.AddWithDeps("d", "abcd", nil)
+	value, ok := lru.Get("a")
+
+	assert.NoError(t, err1)
+	assert.ErrorIs(t, err2, ErrValueTooLarge)
+	assert.True(t, ok)
+	assert.Equal(t, "abc", value)
+	assert.Equal(t, 1, lru.Len())
+}
+
+func TestRemove(t *testing.T) {
+	t.Run("removed", func(t *testing.T) {
+		lru := New[string, int](nil)
+
+		lru.Add("foo", 1001)
+		removed := lru.Remove("foo")
+
+		assert.True(t, removed)
+		assert.Equal(t, 0, lru.Len())
+	})
+
+	t.Run("not_removed", func(t *testing.T) {
+		lru := New[int, int](nil)
+
+		removed := lru.Remove(0)
+
+		assert.False(t, removed)
+		assert.Equal(t, 0, lru.Len())
+	})
+
+	t.Run("with_removed_handler", func(t *testing.T) {
+		var removedKey int
+		var removedValue string
+		var removedTime time.Time
+		lru := NewWithHandler[int, string](nil, RemovedFunc[int, string](func(k int, v string) {
+			removedKey = k
+			removedValue = v
+			removedTime = time.Now()
+		}))
+
+		lru.Add(10, "lorem")
+		lru.Add(15, "ipsum")
+		before := time.Now()
+		shouldBeTrue := lru.Remove(15)
+		after := time.Now()
+		shouldBeFalse := lru.Remove(15)
+
+		assert.True(t, shouldBeTrue)
+		assert.Equal(t, 15, removedKey)
+		assert.Equal(t, "ipsum", removedValue)
+		assert.False(t, removedTime.Before(before))
+		assert.False(t, after.Before(removedTime))
+		assert.Equal(t, 1, lru.Len())
+		assert.False(t, shouldBeFalse)
+	})
+}
+
+func TestAddWithDeps(t *testing.T) {
+	t.Run("cascade_on_remove", func(t *testing.T) {
+		var removed []string
+		lru := NewWithHandler[string, int](nil, RemovedFunc[string, int](func(k string, _ int) {
+			removed = append(removed, k)
+		}))
+
+		lru.Add("base", 1)
+		lru.AddWithDeps("view1", 2, []string{"base"})
+		lru.AddWithDeps("view2", 3, []string{"view1", "other"})
+		lru.Add("other", 4)
+		lru.Remove("base")
+
+		assert.Equal(t, []string{"base", "view1", "view2"}, removed)
+		assert.Equal(t, 1, lru.Len())
+		assert.Equal(t, map[string][]string{}, lru.dependents)
+	})
+
+	t.Run("cascade_on_evict", func(t *testing.T) {
+		var removed []string
+		lru := NewWithHandler[string, int](MaxCount[string, int](3), RemovedFunc[string, int](func(k string, _ int) {
+			removed = append(removed, k)
+		}))
+
+		lru.Add("base", 1)
+		lru.AddWithDeps("view", 2, []string{"base"})
+		lru.Add("x", 3)
+		lru.Add("y", 4)
+		_, ok := lru.Get("view")
+
+		assert.False(t, ok)
+		assert.Equal(t, []string{"base", "view"}, removed)
+		assert.Equal(t, 2, lru.Len())
+	})
+
+	t.Run("cycle", func(t *testing.T) {
+		var removed []string
+		lru := NewWithHandler[string, int](nil, RemovedFunc[string, int](func(k string, _ int) {
+			removed = append(removed, k)
+		}))
+
+		lru.AddWithDeps("a", 1, []string{"c"})
+		lru.AddWithDeps("b", 2, []string{"a"})
+		lru.AddWithDeps("c", 3, []string{"b", "c"})
+		lru.Remove("b")
+
+		assert.Equal(t, []string{"b", "c", "a"}, removed)
+		assert.Equal(t, 0, lru.Len())
+		assert.Empty(t, lru.dependents)
+	})
+
+	t.Run("replace_deps", func(t *testing.T) {
+		lru := New[string, int](nil)
+
+		lru.Add("old", 1)
+		lru.Add("new", 2)
+		lru.AddWithDeps("view", 3, []string{"old"})
+		lru.Add("view", 4)
+		lru.AddWithDeps("view", 5, []string{"new"})
+		lru.Remove("old")
+		value, ok := lru.Get("view")
+		lru.Remove("new")
+		_, ok2 := lru.Get("view")
+
+		assert.True(t, ok)
+		assert.Equal(t, 5, value)
+		assert.False(t, ok2)
+	})
+}
+
+func TestEvict(t *testing.T) {
+	t.Run("implicit_during_add", func(t *testing.T) {
+		lru := New[int, int](MaxCount[int, int](2))
+
+		lru.Add(1, 11)
+		lru.Add(2, 22)
+		lru.Add(1, 11)
+		lru.Add(3, 33)
+		value, ok := lru.Get(2)
+
+		assert.Equal(t, 2, lru.Len())
+		assert.Equal(t, 0, value)
+		assert.False(t, ok)
+	})
+
+	t.Run("explicit", func(t *testing.T) {
+		maxSize := 10
+		policy := PolicyFunc[string, string](func(_, _ string, n int) bool {
+			return n > maxSize
+		})
+		lru := New[string, string](policy)
+
+		lru.Add("doomed", "to eviction")
+		lru.Add("ill-fated", "due to being an eviction target")
+		lru.Add("lucky", "to survive")
+		lru.Add("blessed", "to avoid the evict-pocalypse")
+
+		assert.Equal(t, 4, lru.Len())
+
+		maxSize = 2
+		lru.Evict()
+		_, ok1 := lru.Get("ill-fated")
+		value2, ok2 := lru.Get("lucky")
+		value3, ok3 := lru.Get("blessed")
+
+		assert.Equal(t, 2, lru.Len())
+		assert.False(t, ok1)
+		assert.True(t, ok2)
+		assert.Equal(t, "to survive", value2)
+		assert.True(t, ok3)
+		assert.Equal(t, "to avoid the evict-pocalypse", value3)
+	})
+
+	t.Run("burst_order", func(t *testing.T) {
+		maxSize := 10
+		var removed []int
+		lru := NewWithHandler[int, int](PolicyFunc[int, int](func(_, _ int, n int) bool {
+			return n > maxSize
+		}), RemovedFunc[int, int](func(k, _ int) {
+			removed = append(removed, k)
+		}))
+
+		for i := 0; i < 5; i++ {
+			lru.Add(i, i)
+		}
+		lru.Get(0)
+		maxSize = 1
+		n := lru.Evict()
+
+		assert.Equal(t, 4, n)
+		assert.Equal(t, []int{1, 2, 3, 4}, removed)
+	})
+
+	t.Run("burst_order_reversed", func(t *testing.T) {
+		maxSize := 10
+		var removed []int
+		var lenDuringRemoved []int
+		var lru *Cache[int, int]
+		lru = NewWithHandler[int, int](PolicyFunc[int, int](func(_, _ int, n int) bool {
+			return n > maxSize
+		}), RemovedFunc[int, int](func(k, _ int) {
+			removed = append(removed, k)
+			lenDuringRemoved = append(lenDuringRemoved, lru.Len())
+		}))
+		lru.ReverseEvictionEvents = true
+
+		for i := 0; i < 5; i++ {
+			lru.Add(i, i)
+		}
+		lru.Get(0)
+		maxSize = 1
+		n := lru.Evict()
+		lru.Remove(0)
+
+		assert.Equal(t, 4, n)
+		assert.Equal(t, []int{4, 3, 2, 1, 0}, removed)
+		assert.Equal(t, []int{1, 1, 1, 1, 0}, lenDuringRemoved)
+	})
+}
+
+func TestTwoPhaseEviction(t *testing.T) {
+	pendingKeys := func(lru *Cache[string, int]) []string {
+		keys := []string{}
+		for _, kv := range lru.PendingEvictions() {
+			keys = append(keys, kv.K)
+		}
+		return keys
+	}
+
+	t.Run("commit", func(t *testing.T) {
+		var removed []string
+		lru := NewWithHandler[string, int](MaxCount[string, int](2), &handlerFuncs[string, int]{
+			removed: func(k string, _ int) { removed = append(removed, k) },
+		})
+		lru.TwoPhaseEviction = true
+
+		lru.Add("a", 1)
+		lru.Add("b", 2)
+		lru.Add("c", 3)
+		lru.Add("d", 4)
+		valueA, missesA := lru.PeekAll([]string{"a"})
+
+		assert.Equal(t, []string{"a", "b"}, pendingKeys(lru))
+		assert.Equal(t, 4, lru.Len())
+		assert.Empty(t, missesA)
+		assert.Equal(t, map[string]int{"a": 1}, valueA)
+		assert.Nil(t, removed)
+		assert.Equal(t, []string{"c", "d"}, lru.SurvivingKeys())
+
+		n := lru.CommitEvictions()
+
+		assert.Equal(t, 2, n)
+		assert.Equal(t, []string{"a", "b"}, removed)
+		assert.Equal(t, []string{}, pendingKeys(lru))
+		assert.Equal(t, []string{"d", "c"}, lru.orderedKeysForTest())
+	})
+
+	t.Run("rescue", func(t *testing.T) {
+		lru := New[string, int](MaxCount[string, int](2))
+		lru.TwoPhaseEviction = true
+		lru.Add("a", 1)
+		lru.Add("b", 2)
+		lru.Add("c", 3)
+
+		value, ok := lru.Get("a")
+
+		assert.True(t, ok)
+		assert.Equal(t, 1, value)
+		assert.Equal(t, []string{}, pendingKeys(lru))
+
+		lru.Evict()
+
+		assert.Equal(t, []string{"b"}, pendingKeys(lru))
+
+		lru.Add("b", 20)
+
+		assert.Equal(t, []string{"b", "a", "c"}, lru.orderedKeysForTest())
+
+		lru.Evict()
+
+		assert.Equal(t, []string{"c"}, pendingKeys(lru))
+
+		lru.Remove("c")
+
+		assert.Equal(t, []string{}, pendingKeys(lru))
+		assert.Equal(t, 2, lru.Len())
+	})
+
+	t.Run("grace", func(t *testing.T) {
+		lru := New[string, int](MaxCount[string, int](1))
+		lru.TwoPhaseEviction = true
+		lru.EvictionGrace = 2
+
+		lru.Add("a", 1)
+		lru.Add("b", 2)
+		lru.Add("c", 3)
+
+		assert.Equal(t, []string{"a", "b"}, pendingKeys(lru))
+
+		lru.Evict()
+
+		assert.Equal(t, []string{"b"}, pendingKeys(lru))
+
+		lru.Evict()
+
+		assert.Equal(t, []string{}, pendingKeys(lru))
+		assert.Equal(t, []string{"c"}, lru.orderedKeysForTest())
+	})
+
+	t.Run("negative_grace", func(t *testing.T) {
+		lru := New[string, int](MaxCount[string, int](1))
+		lru.TwoPhaseEviction = true
+		lru.EvictionGrace = -1
+
+		lru.Add("a", 1)
+		lru.Add("b", 2)
+		lru.Evict()
+		lru.Evict()
+
+		assert.Equal(t, []string{"a"}, pendingKeys(lru))
+		assert.Equal(t, 1, lru.CommitEvictions())
+		assert.Equal(t, []string{}, pendingKeys(lru))
+	})
+
+	t.Run("overflow_reject", func(t *testing.T) {
+		lru := New[string, int](MaxCount[string, int](1))
+		lru.TwoPhaseEviction = true
+		lru.Add("a", 1)
+		lru.Add("b", 2)
+		lru.Overflow = OverflowError
+
+		err := lru.AddOrErr("c", 3)
+
+		assert.ErrorIs(t, err, ErrCacheFull)
+		assert.Equal(t, []string{"a"}, pendingKeys(lru))
+		assert.Equal(t, []string{"b", "a"}, lru.orderedKeysForTest())
+	})
+
+	t.Run("clear", func(t *testing.T) {
+		lru := New[string, int](MaxCount[string, int](1))
+		lru.TwoPhaseEviction = true
+		lru.Add("a", 1)
+		lru.Add("b", 2)
+
+		lru.Clear()
+
+		assert.Equal(t, []string{}, pendingKeys(lru))
+		assert.Equal(t, 0, lru.CommitEvictions())
+	})
+}
+
+func TestCapacityOne(t *testing.T) {
+	type recorder struct {
+		lru    *Cache[string, int]
+		events []string
+		readd  func(k string)
+	}
+	newCache := func() *recorder {
+		r := &recorder{}
+		r.lru = NewWithHandler[string, int](MaxCount[string, int](1), &handlerFuncs[string, int]{
+			added: func(k string, _, v int, update bool) {
+				r.events = append(r.events, "+"+k+"="+strconv.Itoa(v)+"/"+strconv.FormatBool(update))
+			},
+			removed: func(k string, _ int) {
+				r.events = append(r.events, "-"+k)
+				if r.readd != nil {
+					r.readd(k)
+				}
+			},
+		})
+		return r
+	}
+
+	t.Run("same_key_updates_in_place", func(t *testing.T) {
+		r := newCache()
+
+		r.lru.Add("a", 1)
+		r.lru.Add("a", 2)
+		value, ok := r.lru.Get("a")
+
+		assert.True(t, ok)
+		assert.Equal(t, 2, value)
+		assert.Equal(t, []string{"+a=1/false", "+a=2/true"}, r.events)
+	})
+
+	t.Run("new_key_evicts_old_key", func(t *testing.T) {
+		r := newCache()
+
+		r.lru.Add("a", 1)
+		r.lru.Add("b", 2)
+
+		assert.Equal(t, []string{"b"}, r.lru.orderedKeysForTest())
+		assert.Equal(t, []string{"+a=1/false", "+b=2/false", "-a"}, r.events)
+	})
+
+	t.Run("new_key_survives_its_own_add", func(t *testing.T) {
+		r := newCache()
+
+		for i := 0; i < 10; i++ {
+			k := strconv.Itoa(i)
+			r.lru.Add(k, i)
+			_, ok := r.lru.Get(k)
+
+			assert.True(t, ok)
+			assert.Equal(t, 1, r.lru.Len())
+		}
+	})
+
+	t.Run("handler_readds_evicted_key", func(t *testing.T) {
+		r := newCache()
+		r.readd = func(k string) {
+			if k == "a" {
+				r.readd = nil
+				r.lru.Add("a", 10)
+			}
+		}
+
+		r.lru.Add("a", 1)
+		r.lru.Add("b", 2)
+
+		assert.Equal(t, []string{"a"}, r.lru.orderedKeysForTest())
+		assert.Equal(t, []string{"+a=1/false", "+b=2/false", "-a", "+a=10/false", "-b"}, r.events)
+	})
+
+	t.Run("handler_readds_new_key", func(t *testing.T) {
+		r := newCache()
+		r.readd = func(k string) {
+			if k == "a" {
+				r.readd = nil
+				r.lru.Add("b", 20)
+			}
+		}
+
+		r.lru.Add("a", 1)
+		r.lru.Add("b", 2)
+		value, ok := r.lru.Get("b")
+
+		assert.True(t, ok)
+		assert.Equal(t, 20, value)
+		assert.Equal(t, 1, r.lru.Len())
+		assert.Equal(t, []string{"+a=1/false", "+b=2/false", "-a", "+b=20/true"}, r.events)
+	})
+}
+
+func TestBoost(t *testing.T) {
+	t.Run("absent", func(t *testing.T) {
+		lru := New[int, int](nil)
+
+		assert.False(t, lru.Boost(1, 1))
+	})
+
+	t.Run("survives_passes", func(t *testing.T) {
+		var removed []int
+		lru := NewWithHandler[int, int](MaxCount[int, int](2), RemovedFunc[int, int](func(k, _ int) {
+			removed = append(removed, k)
+		}))
+
+		lru.Add(1, 1)
+		lru.Add(2, 2)
+		assert.True(t, lru.Boost(1, 2))
+		lru.Add(3, 3)
+		assert.Equal(t, []int{2}, removed)
+		assert.Equal(t, []int{1, 3}, lru.SurvivingKeys())
+		lru.Add(4, 4)
+		assert.Equal(t, []int{2, 3}, removed)
+		lru.Add(5, 5)
+
+		assert.Equal(t, []int{2, 3, 1}, removed)
+		assert.Equal(t, 2, lru.Len())
+	})
+
+	t.Run("surviving_keys", func(t *testing.T) {
+		maxSize := 10
+		lru := New[int, int](PolicyFunc[int, int](func(_, _ int, n int) bool {
+			return n > maxSize
+		}))
+		for i := 0; i < 5; i++ {
+			lru.Add(i, i)
+		}
+		lru.Boost(1, 1)
+		maxSize = 2
+
+		surviving := lru.SurvivingKeys()
+		n := lru.Evict()
+
+		assert.Equal(t, []int{1, 4}, surviving)
+		assert.Equal(t, 3, n)
+		assert.Equal(t, surviving, lru.SurvivingKeys())
+	})
+}
+
+func TestSurvivingKeys(t *testing.T) {
+	t.Run("zero_value", func(t *testing.T) {
+		var lru Cache[int, int]
+
+		assert.Equal(t, []int{}, lru.SurvivingKeys())
+	})
+
+	t.Run("no_policy", func(t *testing.T) {
+		lru := New[int, int](nil)
+		lru.Add(1, 1)
+		lru.Add(2, 2)
+
+		assert.Equal(t, []int{1, 2}, lru.SurvivingKeys())
+	})
+
+	t.Run("simulated", func(t *testing.T) {
+		maxSize := 10
+		lru := New[int, int](PolicyFunc[int, int](func(_, _ int, n int) bool {
+			return n > maxSize
+		}))
+		for i := 0; i < 5; i++ {
+			lru.Add(i, i)
+		}
+		lru.Get(0)
+		maxSize = 2
+
+		surviving := lru.SurvivingKeys()
+
+		assert.Equal(t, []int{4, 0}, surviving)
+		assert.Equal(t, 5, lru.Len())
+		lru.Evict()
+		assert.Equal(t, 2, lru.Len())
+		assert.Equal(t, surviving, lru.SurvivingKeys())
+	})
+
+	t.Run("preferring", func(t *testing.T) {
+		maxSize := 10
+		lru := New[string, int](EvictPreferring[string, int](func(k string, _ int) bool {
+			return k == "b" || k == "d"
+		}, PolicyFunc[string, int](func(_ string, _ int, n int) bool {
+			return n > maxSize
+		})))
+		lru.Add("a", 1)
+		lru.Add("b", 2)
+		lru.Add("c", 3)
+		lru.Add("d", 4)
+		lru.Add("e", 5)
+		maxSize = 2
+
+		surviving := lru.SurvivingKeys()
+		lru.Evict()
+
+		assert.Equal(t, []string{"c", "e"}, surviving)
+		assert.Equal(t, surviving, lru.SurvivingKeys())
+		assert.Equal(t, []string{"e", "c"}, lru.orderedKeysForTest())
+	})
+
+	t.Run("selector", func(t *testing.T) {
+		p := LFU[string, int](5)
+		lru := NewTracked[string, int](p)
+		for _, k := range []string{"a", "b", "c", "d", "e"} {
+			lru.Add(k, 0)
+		}
+		lru.Get("a")
+		lru.Get("c")
+		lru.Get("c")
+		p.maxCount = 2
+
+		surviving := lru.SurvivingKeys()
+		lru.Evict()
+
+		assert.Equal(t, []string{"e", "c"}, surviving)
+		assert.Equal(t, surviving, lru.SurvivingKeys())
+	})
+
+	t.Run("composite_selector", func(t *testing.T) {
+		now := time.Unix(0, 0)
+		p := ExpireAfter[string, int](time.Minute)
+		p.Clock = func() time.Time { return now }
+		lru := NewTracked[string, int](Or[string, int](MaxCount[string, int](10), p))
+		lru.Add("a", 1)
+		lru.Add("b", 2)
+		now = now.Add(time.Second)
+		lru.Add("c", 3)
+		lru.Get("a")
+		now = now.Add(time.Minute - time.Millisecond)
+
+		surviving := lru.SurvivingKeys()
+		lru.Evict()
+
+		assert.Equal(t, []string{"c"}, surviving)
+		assert.Equal(t, surviving, lru.SurvivingKeys())
+	})
+}
+
+func TestWithEvictionCapture(t *testing.T) {
+	t.Run("captures_evictions", func(t *testing.T) {
+		lru := New[string, int](MaxCount[string, int](2))
+
+		lru.Add("a", 1)
+		lru.Add("b", 2)
+		captured := lru.WithEvictionCapture(func() {
+			lru.Add("c", 3)
+			lru.Remove("b")
+			lru.Add("d", 4)
+			lru.Add("e", 5)
+		})
+
+		assert.Equal(t, []struct {
+			K string
+			V int
+		}{{"a", 1}, {"c", 3}}, captured)
+	})
+
+	t.Run("nested", func(t *testing.T) {
+		lru := New[int, int](MaxCount[int, int](1))
+
+		var inner []struct {
+			K int
+			V int
+		}
+		outer := lru.WithEvictionCapture(func() {
+			lru.Add(1, 10)
+			lru.Add(2, 20)
+			inner = lru.WithEvictionCapture(func() {
+				lru.Add(3, 30)
+			})
+		})
+		lru.Add(4, 40)
+
+		assert.Equal(t, []struct {
+			K int
+			V int
+		}{{2, 20}}, inner)
+		assert.Equal(t, []struct {
+			K int
+			V int
+		}{{1, 10}, {2, 20}}, outer)
+	})
+}
+
+func TestRepair(t *testing.T) {
+	t.Run("zero_value", func(t *testing.T) {
+		var lru Cache[string, int]
+
+		assert.Equal(t, 0, lru.Repair())
+	})
+
+	t.Run("healthy", func(t *testing.T) {
+		lru := New[string, int](nil)
+		lru.Add("a", 1)
+		lru.Add("b", 2)
+
+		assert.Equal(t, 0, lru.Repair())
+		assert.Equal(t, 2, lru.Len())
+	})
+
+	t.Run("corrupted", func(t *testing.T) {
+		lru := New[string, int](nil)
+		lru.Add("a", 1)
+		lru.Add("b", 2)
+		lru.Add("c", 3)
+		delete(lru.cache, "a")                                                          // Missing index entry.
+		lru.cache["z"] = list.New().PushFront(&entry[string, int]{key: "z", value: 26}) // Orphaned index entry.
+		lru.ll.PushBack(&entry[string, int]{key: "b", value: 20})                       // Duplicate list element.
+
+		fixed := lru.Repair()
+		value, ok := lru.Get("b")
+
+		assert.Equal(t, 3, fixed)
+		assert.Equal(t, 3, lru.Len())
+		assert.Len(t, lru.cache, 3)
+		assert.True(t, ok)
+		assert.Equal(t, 2, value)
+		assert.Equal(t, 0, lru.Repair())
+	})
+}
+
+func TestToMap(t *testing.T) {
+	t.Run("zero_value", func(t *testing.T) {
+		var lru Cache[string, int]
+
+		m := lru.ToMap()
+
+		assert.NotNil(t, m)
+		assert.Empty(t, m)
+	})
+
+	t.Run("entries", func(t *testing.T) {
+		lru := New[string, int](nil)
+		lru.Add("a", 1)
+		lru.Add("b", 2)
+
+		m := lru.ToMap()
+		m["c"] = 3
+
+		assert.Equal(t, map[string]int{"a": 1, "b": 2, "c": 3}, m)
+		assert.Equal(t, 2, lru.Len())
+	})
+}
+
+func TestRange(t *testing.T) {
+	t.Run("zero_value", func(t *testing.T) {
+		var lru Cache[string, int]
+		var calls int
+
+		lru.Range(func(string, int) bool {
+			calls++
+			return true
+		})
+
+		assert.Equal(t, 0, calls)
+	})
+	t.Run("oldest_first", func(t *testing.T) {
+		lru := New[string, int](nil)
+		lru.Add("a", 1)
+		lru.Add("b", 2)
+		lru.Add("c", 3)
+		lru.Get("a")
+		var keys []string
+		var values []int
+
+		lru.Range(func(k string, v int) bool {
+			keys = append(keys, k)
+			values = append(values, v)
+			return true
+		})
+
+		assert.Equal(t, []string{"b", "c", "a"}, keys)
+		assert.Equal(t, []int{2, 3, 1}, values)
+		assert.Equal(t, []string{"a", "c", "b"}, lru.orderedKeysForTest())
+	})
+	t.Run("stop_early", func(t *testing.T) {
+		lru := New[string, int](nil)
+		lru.Add("a", 1)
+		lru.Add("b", 2)
+		lru.Add("c", 3)
+		var keys []string
+
+		lru.Range(func(k string, _ int) bool {
+			keys = append(keys, k)
+			return k != "b"
+		})
+
+		assert.Equal(t, []string{"a", "b"}, keys)
+	})
+	t.Run("remove_current", func(t *testing.T) {
+		lru := New[string, int](nil)
+		lru.Add("a", 1)
+		lru.Add("b", 2)
+		lru.Add("c", 3)
+		lru.Add("d", 4)
+		var keys []string
+
+		lru.Range(func(k string, v int) bool {
+			keys = append(keys, k)
+			if v%2 == 0 {
+				lru.Remove(k)
+			}
+			return true
+		})
+
+		assert.Equal(t, []string{"a", "b", "c", "d"}, keys)
+		assert.Equal(t, []string{"c", "a"}, lru.orderedKeysForTest())
+	})
+}
+
+func TestRangeInsertionOrder(t *testing.T) {
+	collect := func(lru *Cache[string, int]) (keys []string) {
+		lru.RangeInsertionOrder(func(k string, _ int) bool {
+			keys = append(keys, k)
+			return true
+		})
+		return
+	}
+
+	t.Run("disabled", func(t *testing.T) {
+		lru := New[string, int](nil)
+
+		lru.Add("a", 1)
+
+		assert.Nil(t, collect(lru))
+	})
+
+	t.Run("order", func(t *testing.T) {
+		lru := New[string, int](MaxCount[string, int](3))
+		lru.TrackInsertionOrder = true
+
+		lru.Add("a", 1)
+		lru.Add("b", 2)
+		lru.Add("c", 3)
+		lru.Get("a")
+		lru.Add("b", 20)
+		lru.Add("d", 4)
+
+		assert.Equal(t, []string{"a", "b", "d"}, collect(lru))
+
+		lru.Remove("b")
+
+		assert.Equal(t, []string{"a", "d"}, collect(lru))
+
+		lru.Clear()
+		lru.Add("e", 5)
+
+		assert.Equal(t, []string{"e"}, collect(lru))
+
+		lru.ReplaceAll([]struct {
+			K string
+			V int
+		}{{"f", 6}, {"g", 7}})
+
+		assert.Equal(t, []string{"f", "g"}, collect(lru))
+	})
+
+	t.Run("stop", func(t *testing.T) {
+		lru := New[string, int](nil)
+		lru.TrackInsertionOrder = true
+		lru.Add("a", 1)
+		lru.Add("b", 2)
+		var n int
+
+		lru.RangeInsertionOrder(func(string, int) bool {
+			n++
+			return false
+		})
+
+		assert.Equal(t, 1, n)
+	})
+}
+
+func TestExportBytes(t *testing.T) {
+	enc := func(v int) []byte { return []byte(strconv.Itoa(v)) }
+
+	t.Run("order", func(t *testing.T) {
+		lru := New[string, int](nil)
+		lru.Add("a", 1)
+		lru.Add("b", 2)
+		lru.Add("c", 3)
+		lru.Get("a")
+		var pairs []string
+
+		err := lru.ExportBytes(func(k string) []byte { return []byte(k) }, enc, func(kb, vb []byte) error {
+			pairs = append(pairs, string(kb)+"="+string(vb))
+			return nil
+		})
+
+		assert.NoError(t, err)
+		assert.Equal(t, []string{"b=2", "c=3", "a=1"}, pairs)
+	})
+
+	t.Run("error", func(t *testing.T) {
+		lru := New[string, int](nil)
+		lru.Add("a", 1)
+		lru.Add("b", 2)
+		errStop := errors.New("stop")
+		var n int
+
+		err := lru.ExportBytes(func(k string) []byte { return []byte(k) }, enc, func(_, _ []byte) error {
+			n++
+			return errStop
+		})
+
+		assert.ErrorIs(t, err, errStop)
+		assert.Equal(t, 1, n)
+	})
+
+	t.Run("zero_value", func(t *testing.T) {
+		var lru Cache[string, int]
+
+		err := lru.ExportBytes(func(k string) []byte { return []byte(k) }, enc, func(_, _ []byte) error {
+			return errors.New("unexpected")
+		})
+
+		assert.NoError(t, err)
+	})
+}
+
+func TestKeys(t *testing.T) {
+	t.Run("zero_value", func(t *testing.T) {
+		var lru Cache[string, int]
+
+		keys := lru.Keys()
+
+		assert.NotNil(t, keys)
+		assert.Empty(t, keys)
+	})
+	t.Run("oldest_first", func(t *testing.T) {
+		lru := New[string, int](nil)
+		lru.Add("a", 1)
+		lru.Add("b", 2)
+		lru.Add("c", 3)
+		lru.Get("a")
+
+		keys := lru.Keys()
+
+		assert.Equal(t, []string{"b", "c", "a"}, keys)
+		assert.Equal(t, []string{"a", "c", "b"}, lru.orderedKeysForTest())
+	})
+}
+
+func TestValues(t *testing.T) {
+	t.Run("zero_value", func(t *testing.T) {
+		var lru Cache[string, int]
+
+		values := lru.Values()
+
+		assert.NotNil(t, values)
+		assert.Empty(t, values)
+	})
+	t.Run("oldest_first", func(t *testing.T) {
+		lru := New[string, int](nil)
+		lru.Add("a", 1)
+		lru.Add("b", 2)
+		lru.Add("c", 3)
+		lru.Get("a")
+
+		values := lru.Values()
+
+		assert.Equal(t, []int{2, 3, 1}, values)
+		assert.Equal(t, []string{"b", "c", "a"}, lru.Keys())
+		assert.Equal(t, []string{"a", "c", "b"}, lru.orderedKeysForTest())
+	})
+}
+
+func TestNewest(t *testing.T) {
+	lru := New[string, int](nil)
+	lru.Add("a", 1)
+	lru.Add("b", 2)
+	lru.Add("c", 3)
+	lru.Get("a")
+
+	newest2 := lru.Newest(2)
+	newest10 := lru.Newest(10)
+	newest0 := lru.Newest(0)
+	var empty Cache[string, int]
+	newestEmpty := empty.Newest(1)
+
+	assert.Equal(t, []struct {
+		K string
+		V int
+	}{{"a", 1}, {"c", 3}}, newest2)
+	assert.Equal(t, []struct {
+		K string
+		V int
+	}{{"a", 1}, {"c", 3}, {"b", 2}}, newest10)
+	assert.Empty(t, newest0)
+	assert.NotNil(t, newestEmpty)
+	assert.Empty(t, newestEmpty)
+	assert.Equal(t, []string{"a", "c", "b"}, lru.orderedKeysForTest())
+}
+
+func TestKeySet(t *testing.T) {
+	t.Run("zero_value", func(t *testing.T) {
+		var lru Cache[string, int]
+
+		set := lru.KeySet()
+
+		assert.NotNil(t, set)
+		assert.Empty(t, set)
+	})
+
+	t.Run("keys", func(t *testing.T) {
+		lru := New[string, int](nil)
+		lru.Add("a", 1)
+		lru.Add("b", 2)
+
+		set := lru.KeySet()
+
+		assert.Equal(t, map[string]struct{}{"a": {}, "b": {}}, set)
+		assert.Equal(t, []string{"b", "a"}, lru.orderedKeysForTest())
+	})
+}
+
+func TestGroupBy(t *testing.T) {
+	parity := func(k int, _ string) string {
+		if k%2 == 0 {
+			return "even"
+		}
+		return "odd"
+	}
+
+	t.Run("zero_value", func(t *testing.T) {
+		var lru Cache[int, string]
+
+		assert.Equal(t, map[string]int{}, lru.GroupBy(parity))
+		assert.Equal(t, map[string][]int{}, lru.GroupKeysBy(parity))
+	})
+
+	t.Run("entries", func(t *testing.T) {
+		lru := New[int, string](nil)
+		for i := 1; i <= 5; i++ {
+			lru.Add(i, strconv.Itoa(i))
+		}
+		lru.Get(1)
+
+		counts := lru.GroupBy(parity)
+		keys := lru.GroupKeysBy(parity)
+
+		assert.Equal(t, map[string]int{"even": 2, "odd": 3}, counts)
+		assert.Equal(t, map[string][]int{"even": {2, 4}, "odd": {3, 5, 1}}, keys)
+	})
+}
+
+func TestEstimatedBytes(t *testing.T) {
+	t.Run("zero_value", func(t *testing.T) {
+		var lru Cache[string, string]
+
+		assert.Greater(t, lru.EntryOverhead(), int64(0))
+		assert.Equal(t, int64(0), lru.EstimatedBytes(nil))
+	})
+
+	t.Run("entries", func(t *testing.T) {
+		lru := New[string, string](nil)
+		lru.Add("foo", "bar")
+		lru.Add("hello", "world")
+		overhead := lru.EntryOverhead()
+
+		assert.Equal(t, 2*overhead, lru.EstimatedBytes(nil))
+		assert.Equal(t, 2*overhead+16, lru.EstimatedBytes(func(k, v string) int64 {
+			return int64(len(k) + len(v))
+		}))
+	})
+
+	t.Run("recency_order", func(t *testing.T) {
+		lru := New[string, string](nil)
+		lru.Add("a", "1")
+		lru.Add("b", "2")
+		lru.Add("c", "3")
+		lru.Get("a")
+		var keys []string
+
+		lru.EstimatedBytes(func(k, _ string) int64 {
+			keys = append(keys, k)
+			return 0
+		})
+
+		assert.Equal(t, []string{"b", "c", "a"}, keys)
+	})
+}
+
+func TestLifetimeTotals(t *testing.T) {
+	lru := New[int, int](MaxCount[int, int](2))
+
+	lru.Add(1, 1)
+	lru.Add(2, 2)
+	lru.Add(2, 20)
+	lru.Add(3, 3)
+	lru.Remove(2)
+	lru.Add(4, 4)
+	lru.Clear()
+	lru.Add(5, 5)
+
+	assert.Equal(t, uint64(5), lru.TotalAdded())
+	assert.Equal(t, uint64(4), lru.TotalRemoved())
+}
+
+type traceHandler struct {
+	events []string
+}
+
+func (h *traceHandler) Added(k int, _, v int, update bool) {
+	h.events = append(h.events, "+"+strconv.Itoa(k)+"="+strconv.Itoa(v)+"/"+strconv.FormatBool(update))
+}
+
+func (h *traceHandler) Removed(k int, _ int) {
+	h.events = append(h.events, "-"+strconv.Itoa(k))
+}
+
+func (h *traceHandler) Promoted(k int, _, _ int) {
+	h.events = append(h.events, "^"+strconv.Itoa(k))
+}
+
+func TestDeterministicTrace(t *testing.T) {
+	run := func() []string {
+		h := &traceHandler{}
+		lru := NewWithHandler[int, int](MaxCount[int, int](16), h)
+		r := rand.New(rand.NewSource(1))
+		for i := 0; i < 5000; i++ {
+			k := r.Intn(64)
+			switch r.Intn(10) {
+			case 0:
+				lru.Remove(k)
+			case 1:
+				lru.AddWithDeps(k, i, []int{r.Intn(64), r.Intn(64)})
+			case 2:
+				if r.Intn(50) == 0 {
+					lru.Clear()
+				}
+			case 3, 4, 5:
+				lru.Get(k)
+			default:
+				lru.Add(k, i)
+			}
+		}
+		lru.Clear()
+		return h.events
+	}
+
+	trace1 := run()
+	trace2 := run()
+
+	assert.NotEmpty(t, trace1)
+	assert.Equal(t, trace1, trace2)
+}
+
+func TestReplaceAll(t *testing.T) {
+	t.Run("basic", func(t *testing.T) {
+		var events []string
+		lru := NewWithHandler[string, int](MaxCount[string, int](3), &handlerFuncs[string, int]{
+			added:   func(k string, _, _ int, _ bool) { events = append(events, "+"+k) },
+			removed: func(k string, _ int) { events = append(events, "-"+k) },
+		})
+		lru.Add("a", 1)
+		lru.Add("b", 2)
+		events = nil
+
+		lru.ReplaceAll([]struct {
+			K string
+			V int
+		}{{"c", 3}, {"d", 4}, {"c", 30}, {"e", 5}, {"f", 6}})
+		_, okA := lru.Get("a")
+		_, okD := lru.Get("d")
+		valueC, okC := lru.Get("c")
+
+		assert.Equal(t, []string{"-a", "-b", "+d", "+c", "+e", "+f", "-d"}, events)
+		assert.False(t, okA)
+		assert.False(t, okD)
+		assert.True(t, okC)
+		assert.Equal(t, 30, valueC)
+		assert.Equal(t, 3, lru.Len())
+		assert.Equal(t, []string{"e", "f", "c"}, lru.SurvivingKeys())
+	})
+
+	t.Run("zero_value", func(t *testing.T) {
+		var lru Cache[string, int]
+
+		lru.ReplaceAll([]struct {
+			K string
+			V int
+		}{{"a", 1}})
+		value, ok := lru.Get("a")
+
+		assert.True(t, ok)
+		assert.Equal(t, 1, value)
+		assert.Equal(t, uint64(1), lru.TotalAdded())
+		assert.Equal(t, uint64(0), lru.TotalRemoved())
+	})
+
+	t.Run("add_checks", func(t *testing.T) {
+		var events []string
+		lru := NewWithHandler[string, int](nil, &handlerFuncs[string, int]{
+			added: func(k string, _, _ int, _ bool) { events = append(events, "+"+k) },
+		})
+		lru.MaxValueSize = 10
+		lru.ValueSize = func(v int) int64 { return int64(v) }
+		lru.MaxDistinctKeys = 3
+		lru.Add("a", 1)
+		events = nil
+
+		lru.ReplaceAll([]struct {
+			K string
+			V int
+		}{{"a", 2}, {"big", 11}, {"b", 3}, {"b", 99}, {"c", 4}, {"d", 5}})
+		valueB, _ := lru.Peek("b")
+
+		assert.Equal(t, []string{"+a", "+b", "+c"}, events)
+		assert.Equal(t, 3, valueB)
+		assert.Equal(t, uint64(4), lru.Stats().Adds)
+		assert.Equal(t, 3, lru.DistinctKeys())
+	})
+
+	t.Run("disabled", func(t *testing.T) {
+		var events []string
+		lru := NewWithHandler[string, int](MaxCount[string, int](0), &handlerFuncs[string, int]{
+			added: func(k string, _, _ int, _ bool) { events = append(events, "+"+k) },
+		})
+
+		lru.ReplaceAll([]struct {
+			K string
+			V int
+		}{{"a", 1}})
+
+		assert.Empty(t, events)
+		assert.Equal(t, 0, lru.Len())
+	})
+
+	t.Run("clears_deadlines", func(t *testing.T) {
+		now := time.Unix(1000, 0)
+		lru := New[string, int](nil)
+		lru.Clock = func() time.Time { return now }
+		lru.AddUntil("a", 1, now.Add(time.Minute))
+		lru.ReplaceAll([]struct {
+			K string
+			V int
+		}{{"a", 2}})
+		now = now.Add(time.Hour)
+
+		value, ok := lru.Get("a")
+
+		assert.True(t, ok)
+		assert.Equal(t, 2, value)
+	})
+
+	t.Run("forgets_errors", func(t *testing.T) {
+		errLoad := errors.New("load failed")
+		lru := New[string, int](nil)
+		_, _ = lru.GetOrAddWithErrorCaching("a", func() (int, error) { return 0, errLoad }, time.Hour)
+
+		_, _ = lru.GetOrAddWithErrorCaching("b", func() (int, error) { return 0, errLoad }, time.Hour)
+
+		lru.ReplaceAll([]struct {
+			K string
+			V int
+		}{{"a", 1}})
+
+		assert.Len(t, lru.failures, 1)
+		assert.Contains(t, lru.failures, "b")
+	})
+}
+
+func TestOnEmpty(t *testing.T) {
+	t.Run("remove", func(t *testing.T) {
+		var n int
+		lru := New[string, int](nil)
+		lru.OnEmpty = func() { n++ }
+
+		lru.Remove("a")
+		lru.Add("a", 1)
+		lru.Add("b", 2)
+		lru.Remove("a")
+
+		assert.Equal(t, 0, n)
+
+		lru.Remove("b")
+
+		assert.Equal(t, 1, n)
+
+		lru.Remove("b")
+		lru.Clear()
+
+		assert.Equal(t, 1, n)
+	})
+
+	t.Run("cascade", func(t *testing.T) {
+		var events []string
+		lru := NewWithHandler[string, int](nil, &handlerFuncs[string, int]{
+			removed: func(k string, _ int) { events = append(events, "-"+k) },
+		})
+		lru.OnEmpty = func() { events = append(events, "empty") }
+
+		lru.Add("a", 1)
+		lru.AddWithDeps("b", 2, []string{"a"})
+		lru.AddWithDeps("c", 3, []string{"a"})
+		lru.Remove("a")
+
+		assert.Equal(t, []string{"-a", "-b", "-c", "empty"}, events)
+	})
+
+	t.Run("evict_reversed", func(t *testing.T) {
+		var events []string
+		lru := NewWithHandler[string, int](nil, &handlerFuncs[string, int]{
+			removed: func(k string, _ int) { events = append(events, "-"+k) },
+		})
+		lru.ReverseEvictionEvents = true
+		lru.OnEmpty = func() { events = append(events, "empty") }
+		lru.Add("a", 1)
+		lru.Add("b", 2)
+		lru.Policy = PolicyFunc[string, int](func(string, int, int) bool {
+			return true
+		})
+
+		lru.Evict()
+
+		assert.Equal(t, []string{"-b", "-a", "empty"}, events)
+	})
+
+	t.Run("clear", func(t *testing.T) {
+		var n int
+		lru := New[string, int](nil)
+		lru.OnEmpty = func() { n++ }
+
+		lru.Add("a", 1)
+		lru.Clear()
+
+		assert.Equal(t, 1, n)
+
+		lru.ReplaceAll([]struct {
+			K string
+			V int
+		}{{"a", 1}})
+		lru.ReplaceAll(nil)
+
+		assert.Equal(t, 2, n)
+	})
+
+	t.Run("refilled", func(t *testing.T) {
+		var n int
+		var lru *Cache[string, int]
+		lru = NewWithHandler[string, int](nil, &handlerFuncs[string, int]{
+			removed: func(k string, _ int) {
+				if k == "a" {
+					lru.Add("b", 2)
+				}
+			},
+		})
+		lru.OnEmpty = func() { n++ }
+
+		lru.Add("a", 1)
+		lru.Remove("a")
+
+		assert.Equal(t, 0, n)
+		assert.Equal(t, 1, lru.Len())
+	})
+}
+
+func TestReserve(t *testing.T) {
+	t.Run("zero_value", func(t *testing.T) {
+		var lru Cache[string, int]
+
+		lru.Reserve(0)
+
+		assert.Nil(t, lru.cache)
+
+		lru.Reserve(10)
+		lru.Add("a", 1)
+
+		assert.Equal(t, 1, lru.Len())
+	})
+
+	t.Run("keeps_items", func(t *testing.T) {
+		lru := New[string, int](MaxCount[string, int](2))
+		lru.Add("a", 1)
+		lru.Add("b", 2)
+
+		lru.Reserve(100)
+		lru.Add("c", 3)
+		value, ok := lru.Get("b")
+
+		assert.True(t, ok)
+		assert.Equal(t, 2, value)
+		assert.Equal(t, []string{"b", "c"}, lru.orderedKeysForTest())
+	})
+
+	t.Run("disable_lazy_init", func(t *testing.T) {
+		lru := Cache[string, int]{DisableLazyInit: true}
+
+		assert.Panics(t, func() { lru.Reserve(1) })
+	})
+}
+
+func TestClear(t *testing.T) {
+	t.Run("basic", func(t *testing.T) {
+		var removed []int
+		lru := NewWithHandler[int, int](nil, RemovedFunc[int, int](func(k, v int) {
+			removed = append(removed, k, v)
+		}))
+
+		lru.Add(1, 2)
+		lru.Add(3, 4)
+		lru.Add(5, 6)
+		lru.Clear()
+
+		assert.Equal(t, 0, lru.Len())
+		assert.Equal(t, []int{1, 2, 3, 4, 5, 6}, removed)
+	})
+
+	t.Run("recency_order", func(t *testing.T) {
+		var removed []int
+		lru := NewWithHandler[int, int](nil, RemovedFunc[int, int](func(k, _ int) {
+			removed = append(removed, k)
+		}))
+
+		for i := 0; i < 100; i++ {
+			lru.Add(i, i)
+		}
+		expected := make([]int, 0, 100)
+		for i := 0; i < 100; i += 2 {
+			lru.Get(i)
+			expected = append(expected, i+1)
+		}
+		for i := 0; i < 100; i += 2 {
+			expected = append(expected, i)
+		}
+		lru.Clear()
+
+		assert.Equal(t, expected, removed)
+	})
+
+	t.Run("reuse", func(t *testing.T) {
+		lru := New[int, int](nil)
+		lru.DisableLazyInit = true
+
+		lru.Add(1, 1)
+		lru.Clear()
+		lru.Add(2, 2)
+		_, ok1 := lru.Get(1)
+		value2, ok2 := lru.Get(2)
+
+		assert.Equal(t, 1, lru.Len())
+		assert.False(t, ok1)
+		assert.True(t, ok2)
+		assert.Equal(t, 2, value2)
+	})
+
+	t.Run("reentrant_add", func(t *testing.T) {
+		var removed []int
+		var lru *Cache[int, string]
+		lru = NewWithHandler[int, string](MaxCount[int, string](2), RemovedFunc[int, string](func(k int, v string) {
+			removed = append(removed, k)
+			if v == "again" {
+				lru.Add(-k, "re-added")
+			}
+		}))
+
+		lru.Add(1, "once")
+		lru.Add(2, "again")
+		lru.Clear()
+		value, ok := lru.Get(-2)
+		_, ok1 := lru.Get(1)
+		_, ok2 := lru.Get(2)
+
+		assert.Equal(t, []int{1, 2}, removed)
+		assert.Equal(t, 1, lru.Len())
+		assert.True(t, ok)
+		assert.Equal(t, "re-added", value)
+		assert.False(t, ok1)
+		assert.False(t, ok2)
+	})
+}
+
+func TestClearSilent(t *testing.T) {
 	var removed []int
-	lru := NewWithHandler[int, int](nil, RemovedFunc[int, int](func(k, v int) {
-		removed = append(removed, k, v)
+	var zeroed int
+	var emptied bool
+	lru := NewWithHandler[int, int](nil, RemovedFunc[int, int](func(k, _ int) {
+		removed = append(removed, k)
 	}))
+	lru.ZeroOnRemove = func(*int) { zeroed++ }
+	lru.OnEmpty = func() { emptied = true }
 
-	lru.Add(1, 2)
-	lru.Add(3, 4)
-	lru.Add(5, 6)
+	lru.Add(1, 1)
+	lru.Add(2, 2)
+	lru.ClearSilent()
+	lru.Add(3, 3)
 	lru.Clear()
 
 	assert.Equal(t, 0, lru.Len())
-	assert.Equal(t, []int{1, 2, 3, 4, 5, 6}, removed)
+	assert.Equal(t, []int{3}, removed)
+	assert.Equal(t, 3, zeroed)
+	assert.True(t, emptied)
+	assert.Equal(t, uint64(3), lru.TotalRemoved())
 }
 
 /*
@@ -0,0 +1,137 @@
+// Copyright 2022 The policy-lru Authors. All rights reserved.
+//
+// Use of this source code is governed by the Apache License, Version
+// 2.0 (the "License"); you may not use this file except in compliance
+// with the License. You may find a copy of the license in the file
+// LICENSE or at  http://www.apache.org/licenses/LICENSE-2.0.
+
+package policylru
+
+import "container/list"
+
+// Clone returns an independent copy of the cache, holding the same
+// items in the same recency order. Changes to the copy do not affect
+// the original, and vice versa. Clone does not change the recency of
+// any item or generate any Handler events.
+//
+// Values are copied by assignment, so a value which shares memory,
+// such as a slice or map, shares it with the original too. The
+// exported fields are copied as they are, so the copy shares its
+// Handler and other configured components with the original rather
+// than having copies of them.
+//
+// The exception is a built-in policy which implements Configurable,
+// such as MaxCountVar or MaxCountAndSize: the copy gets its own copy
+// of the policy, so changing a limit on either cache does not change
+// it for the other. If the policy also tracks the cache through
+// Handler events, it is only copied when it is installed as the
+// Handler too, as by NewTracked, and the copy of the policy becomes
+// the copy's Handler. Any other policy is shared, and a stateful one,
+// such as a custom policy which tracks the total size of the cache,
+// sees events from both caches and should be replaced on the copy.
+//
+// Along with the items, the copy keeps their dependencies, deadlines,
+// boosts and access times, the statistics and the distinct keys seen.
+// It does not inherit the original's key watchers, op log, hot key
+// counts or hit window, which start out disabled.
+// policyCloner is implemented by built-in policies which have
+// adjustable limits, so that Clone can give the copy its own limits.
+type policyCloner[Key, Value any] interface {
+	clonePolicy() Policy[Key, Value]
+}
+
+func (c *Cache[Key, Value]) Clone() *Cache[Key, Value] {
+	d := &Cache[Key, Value]{}
+	d.Policy, d.Handler = c.clonePolicy()
+	d.RankPromotions = c.RankPromotions
+	d.KeyNormalizer = c.KeyNormalizer
+	d.MaxDistinctKeys = c.MaxDistinctKeys
+	d.DisableLazyInit = c.DisableLazyInit
+	d.ReverseEvictionEvents = c.ReverseEvictionEvents
+	d.Bloom = c.Bloom
+	d.DefaultValue = c.DefaultValue
+	d.CopyOnGet = c.CopyOnGet
+	d.CopyOnAdd = c.CopyOnAdd
+	d.Overflow = c.Overflow
+	d.Validator = c.Validator
+	d.ZeroOnRemove = c.ZeroOnRemove
+	d.TrackInsertionOrder = c.TrackInsertionOrder
+	d.OnEmpty = c.OnEmpty
+	d.MaxValueSize = c.MaxValueSize
+	d.ValueSize = c.ValueSize
+	d.Fallback = c.Fallback
+	d.RecordAccessTimes = c.RecordAccessTimes
+	d.Clock = c.Clock
+	d.TwoPhaseEviction = c.TwoPhaseEviction
+	d.EvictionGrace = c.EvictionGrace
+	d.gen = c.gen
+	d.totalAdded = c.totalAdded
+	d.totalRemoved = c.totalRemoved
+	d.stats = c.stats
+	d.pendingN = c.pendingN
+	d.round = c.round
+	if c.seen != nil {
+		d.seen = make(map[Key]struct{}, len(c.seen))
+		for k := range c.seen {
+			d.seen[k] = struct{}{}
+		}
+	}
+	if c.failures != nil {
+		d.failures = make(map[Key]failure, len(c.failures))
+		for k, f := range c.failures {
+			d.failures[k] = f
+		}
+	}
+	if c.dependents != nil {
+		d.dependents = make(map[Key][]Key, len(c.dependents))
+		for k, deps := range c.dependents {
+			d.dependents[k] = append([]Key(nil), deps...)
+		}
+	}
+	if c.cache == nil {
+		return d
+	}
+	d.ll = list.New()
+	d.cache = make(map[Key]*list.Element, len(c.cache))
+	clones := make(map[*entry[Key, Value]]*entry[Key, Value], len(c.cache))
+	for ele := c.ll.Back(); ele != nil; ele = ele.Prev() {
+		e := ele.Value.(*entry[Key, Value])
+		f := *e
+		if e.deps != nil {
+			f.deps = append([]Key(nil), e.deps...)
+		}
+		if e.access != nil {
+			a := *e.access
+			f.access = &a
+		}
+		f.inserted = nil
+		d.cache[f.key] = d.ll.PushFront(&f)
+		clones[e] = &f
+	}
+	if c.insertion != nil {
+		d.insertion = list.New()
+		for ele := c.insertion.Front(); ele != nil; ele = ele.Next() {
+			f := clones[ele.Value.(*entry[Key, Value])]
+			f.inserted = d.insertion.PushBack(f)
+		}
+	}
+	return d
+}
+
+// clonePolicy returns the Policy and Handler for a copy of the cache,
+// copying the policy if it is a policyCloner which can be copied
+// without losing track of the cache's events.
+func (c *Cache[Key, Value]) clonePolicy() (Policy[Key, Value], Handler[Key, Value]) {
+	pc, ok := c.Policy.(policyCloner[Key, Value])
+	if !ok {
+		return c.Policy, c.Handler
+	}
+	if _, tracked := c.Policy.(Handler[Key, Value]); !tracked {
+		return pc.clonePolicy(), c.Handler
+	}
+	if any(c.Handler) != any(c.Policy) {
+		return c.Policy, c.Handler
+	}
+	p := pc.clonePolicy()
+	return p, p.(Handler[Key, Value])
+}
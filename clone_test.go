@@ -0,0 +1,80 @@
+// Copyright 2022 The policy-lru Authors. All rights reserved.
+//
+// Use of this source code is governed by the Apache License, Version
+// 2.0 (the "License"); you may not use this file except in compliance
+// with the License. You may find a copy of the license in the file
+// LICENSE or at  http://www.apache.org/licenses/LICENSE-2.0.
+
+package policylru
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestClone(t *testing.T) {
+	t.Run("zero_value", func(t *testing.T) {
+		var lru Cache[string, int]
+
+		clone := lru.Clone()
+		clone.Add("a", 1)
+
+		assert.Equal(t, 0, lru.Len())
+		assert.Equal(t, []string{"a"}, clone.orderedKeysForTest())
+	})
+	t.Run("independent", func(t *testing.T) {
+		var removed []string
+		lru := NewWithHandler[string, int](MaxCount[string, int](3), RemovedFunc[string, int](func(k string, _ int) {
+			removed = append(removed, k)
+		}))
+		lru.TrackInsertionOrder = true
+		lru.Add("a", 1)
+		lru.Add("b", 2)
+		lru.AddWithDeps("c", 3, []string{"a"})
+		lru.Get("a")
+
+		clone := lru.Clone()
+
+		assert.Equal(t, []string{"a", "c", "b"}, clone.orderedKeysForTest())
+		assert.Equal(t, lru.Stats(), clone.Stats())
+		assert.Empty(t, removed)
+
+		clone.Add("d", 4)
+		clone.Add("a", 10)
+		clone.Remove("a")
+		var insertion []string
+		clone.RangeInsertionOrder(func(k string, _ int) bool {
+			insertion = append(insertion, k)
+			return true
+		})
+		valueA, _ := lru.Get("a")
+
+		assert.Equal(t, []string{"b", "a", "c"}, removed)
+		assert.Equal(t, []string{"d"}, clone.orderedKeysForTest())
+		assert.Equal(t, []string{"d"}, insertion)
+		assert.Equal(t, []string{"a", "c", "b"}, lru.orderedKeysForTest())
+		assert.Equal(t, 1, valueA)
+
+		lru.Remove("a")
+
+		assert.Equal(t, []string{"b"}, lru.orderedKeysForTest())
+	})
+	t.Run("tracked_policy_state", func(t *testing.T) {
+		lru := NewTracked[string, int](LFU[string, int](2))
+		lru.Add("a", 1)
+		lru.Add("b", 2)
+		lru.Get("a")
+
+		clone := lru.Clone()
+		clone.Get("b")
+		clone.Get("b")
+		clone.Add("c", 3)
+		lru.Add("c", 3)
+
+		assert.NotSame(t, lru.Policy, clone.Policy)
+		assert.Same(t, clone.Policy, clone.Handler)
+		assert.Equal(t, []string{"c", "a"}, lru.orderedKeysForTest())
+		assert.Equal(t, []string{"c", "b"}, clone.orderedKeysForTest())
+	})
+}
@@ -0,0 +1,99 @@
+// Copyright 2022 The policy-lru Authors. All rights reserved.
+//
+// Use of this source code is governed by the Apache License, Version
+// 2.0 (the "License"); you may not use this file except in compliance
+// with the License. You may find a copy of the license in the file
+// LICENSE or at  http://www.apache.org/licenses/LICENSE-2.0.
+
+package policylru
+
+import (
+	"errors"
+	"fmt"
+	"time"
+)
+
+// Configurable is an optional extension to Policy for policies whose
+// parameters can be read and changed by name, without knowing the
+// policy's concrete type. This supports, for example, an administrative
+// endpoint which adjusts a cache's limits while it is running.
+//
+// The built-in policies which have adjustable limits implement
+// Configurable: MaxCountVar, MaxSize, MaxCountAndSize, MaxCountMinRetain,
+// ExpireAfter, LFU and Adaptive. Their documentation lists the
+// parameter names. MaxCount, whose count is fixed, does not.
+type Configurable interface {
+	// Get returns the current value of the named parameter, or false
+	// if the policy has no such parameter.
+	Get(param string) (value any, ok bool)
+	// Set changes the value of the named parameter. It returns an error
+	// wrapping ErrUnknownParameter if the policy has no such parameter,
+	// or an error describing why the value is unacceptable.
+	Set(param string, value any) error
+}
+
+// ErrUnknownParameter is wrapped by the error returned when a
+// Configurable policy is asked to set a parameter it does not have.
+var ErrUnknownParameter = errors.New("policylru: unknown parameter")
+
+// ErrNotConfigurable is returned by ConfigurePolicy when the cache's
+// policy does not implement Configurable.
+var ErrNotConfigurable = errors.New("policylru: policy is not configurable")
+
+// ConfigurePolicy changes a parameter of the cache's eviction policy,
+// which must implement Configurable, and then runs Evict so that the
+// new value takes effect immediately. For example, lowering the "count"
+// parameter of a MaxCountVar policy evicts the surplus items at once.
+func (c *Cache[Key, Value]) ConfigurePolicy(param string, value any) error {
+	cp, ok := c.Policy.(Configurable)
+	if !ok {
+		return ErrNotConfigurable
+	}
+	if err := cp.Set(param, value); err != nil {
+		return err
+	}
+	c.Evict()
+	return nil
+}
+
+func unknownParam(param string) error {
+	return fmt.Errorf("%w %q", ErrUnknownParameter, param)
+}
+
+// intParam converts the value of an integer parameter to an int.
+func intParam(param string, value any) (int, error) {
+	switch v := value.(type) {
+	case int:
+		return v, nil
+	case int8:
+		return int(v), nil
+	case int16:
+		return int(v), nil
+	case int32:
+		return int(v), nil
+	case int64:
+		return int(v), nil
+	case uint:
+		return int(v), nil
+	case uint8:
+		return int(v), nil
+	case uint16:
+		return int(v), nil
+	case uint32:
+		return int(v), nil
+	case uint64:
+		return int(v), nil
+	default:
+		return 0, fmt.Errorf("policylru: parameter %q: expected an integer, got %T", param, value)
+	}
+}
+
+// durationParam converts the value of a duration parameter to a
+// time.Duration.
+func durationParam(param string, value any) (time.Duration, error) {
+	d, ok := value.(time.Duration)
+	if !ok {
+		return 0, fmt.Errorf("policylru: parameter %q: expected a time.Duration, got %T", param, value)
+	}
+	return d, nil
+}
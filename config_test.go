@@ -0,0 +1,152 @@
+// Copyright 2022 The policy-lru Authors. All rights reserved.
+//
+// Use of this source code is governed by the Apache License, Version
+// 2.0 (the "License"); you may not use this file except in compliance
+// with the License. You may find a copy of the license in the file
+// LICENSE or at  http://www.apache.org/licenses/LICENSE-2.0.
+
+package policylru
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+var (
+	_ Configurable = &MaxCountVar[string, int]{}
+	_ Configurable = &MaxCountAndSizePolicy[string, int]{}
+	_ Configurable = &AdaptivePolicy[string, int]{}
+	_ Configurable = &MaxCountMinRetainPolicy[string, int]{}
+	_ Configurable = &ExpireAfterPolicy[string, int]{}
+	_ Configurable = &LFUPolicy[string, int]{}
+)
+
+func TestConfigurePolicy(t *testing.T) {
+	t.Run("max_count_var", func(t *testing.T) {
+		lru := New[string, int](NewMaxCountVar[string, int](3))
+		lru.Add("a", 1)
+		lru.Add("b", 2)
+		lru.Add("c", 3)
+
+		err := lru.ConfigurePolicy("count", int64(1))
+		count, ok := lru.Policy.(Configurable).Get("count")
+
+		assert.NoError(t, err)
+		assert.True(t, ok)
+		assert.Equal(t, 1, count)
+		assert.Equal(t, []string{"c"}, lru.orderedKeysForTest())
+	})
+
+	t.Run("max_count_and_size", func(t *testing.T) {
+		p := MaxCountAndSize[string, int](10, 100, func(v int) uint64 { return uint64(v) })
+		lru := NewTracked[string, int](p)
+		lru.Add("a", 40)
+		lru.Add("b", 40)
+
+		err1 := lru.ConfigurePolicy("bytes", 50)
+		err2 := lru.ConfigurePolicy("bytes", -1)
+		err3 := p.Set("bytes", uint64(60))
+		bytes, _ := p.Get("bytes")
+		err4 := p.Set("count", 5)
+		count, _ := p.Get("count")
+
+		assert.NoError(t, err1)
+		assert.EqualError(t, err2, `policylru: parameter "bytes": must not be negative`)
+		assert.NoError(t, err3)
+		assert.Equal(t, uint64(60), bytes)
+		assert.NoError(t, err4)
+		assert.Equal(t, 5, count)
+		assert.Equal(t, []string{"b"}, lru.orderedKeysForTest())
+	})
+
+	t.Run("adaptive", func(t *testing.T) {
+		p := Adaptive[string, int](2, 4)
+
+		err1 := p.Set("min", 3)
+		limit, _ := p.Get("limit")
+		err2 := p.Set("max", 2)
+		err3 := p.Set("limit", 3)
+
+		assert.NoError(t, err1)
+		assert.Equal(t, 3, limit)
+		assert.EqualError(t, err2, `policylru: parameter "max": requires 0 < min <= max`)
+		assert.ErrorIs(t, err3, ErrUnknownParameter)
+	})
+
+	t.Run("lfu", func(t *testing.T) {
+		lru := NewTracked[string, int](LFU[string, int](3))
+		lru.Add("a", 1)
+		lru.Add("b", 2)
+		lru.Add("c", 3)
+		lru.Get("a")
+
+		err := lru.ConfigurePolicy("count", 2)
+		count, _ := lru.Policy.(Configurable).Get("count")
+
+		assert.NoError(t, err)
+		assert.Equal(t, 2, count)
+		assert.Equal(t, []string{"a", "c"}, lru.orderedKeysForTest())
+	})
+
+	t.Run("expire_after", func(t *testing.T) {
+		now := time.Unix(1000, 0)
+		p := ExpireAfter[string, int](time.Hour)
+		p.Clock = func() time.Time { return now }
+		lru := NewTracked[string, int](p)
+		lru.Add("a", 1)
+		now = now.Add(time.Minute)
+		lru.Add("b", 2)
+		now = now.Add(time.Minute)
+
+		err1 := lru.ConfigurePolicy("ttl", 90*time.Second)
+		err2 := lru.ConfigurePolicy("ttl", 90)
+		ttl, _ := p.Get("ttl")
+
+		assert.NoError(t, err1)
+		assert.EqualError(t, err2, `policylru: parameter "ttl": expected a time.Duration, got int`)
+		assert.Equal(t, 90*time.Second, ttl)
+		assert.Equal(t, []string{"b"}, lru.orderedKeysForTest())
+	})
+
+	t.Run("max_count_min_retain", func(t *testing.T) {
+		now := time.Unix(1000, 0)
+		p := MaxCountMinRetain[string, int](3, time.Hour)
+		p.Clock = func() time.Time { return now }
+		lru := NewTracked[string, int](p)
+		lru.Add("a", 1)
+		lru.Add("b", 2)
+		now = now.Add(time.Minute)
+		lru.Add("c", 3)
+
+		err1 := lru.ConfigurePolicy("count", 1)
+		before := lru.orderedKeysForTest()
+		err2 := lru.ConfigurePolicy("retain", time.Minute)
+		count, _ := p.Get("count")
+		retain, _ := p.Get("retain")
+
+		assert.NoError(t, err1)
+		assert.Equal(t, []string{"c", "b", "a"}, before)
+		assert.NoError(t, err2)
+		assert.Equal(t, 1, count)
+		assert.Equal(t, time.Minute, retain)
+		assert.Equal(t, []string{"c"}, lru.orderedKeysForTest())
+	})
+
+	t.Run("errors", func(t *testing.T) {
+		lru := New[string, int](NewMaxCountVar[string, int](3))
+
+		err1 := lru.ConfigurePolicy("size", 1)
+		err2 := lru.ConfigurePolicy("count", "1")
+		_, ok := lru.Policy.(Configurable).Get("size")
+		lru.Policy = PolicyFunc[string, int](func(string, int, int) bool { return false })
+		err3 := lru.ConfigurePolicy("count", 1)
+
+		assert.EqualError(t, err1, `policylru: unknown parameter "size"`)
+		assert.ErrorIs(t, err1, ErrUnknownParameter)
+		assert.EqualError(t, err2, `policylru: parameter "count": expected an integer, got string`)
+		assert.False(t, ok)
+		assert.ErrorIs(t, err3, ErrNotConfigurable)
+	})
+}
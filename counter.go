@@ -0,0 +1,41 @@
+// Copyright 2022 The policy-lru Authors. All rights reserved.
+//
+// Use of this source code is governed by the Apache License, Version
+// 2.0 (the "License"); you may not use this file except in compliance
+// with the License. You may find a copy of the license in the file
+// LICENSE or at  http://www.apache.org/licenses/LICENSE-2.0.
+
+package policylru
+
+// Number is a constraint that permits any integer or floating-point
+// type.
+type Number interface {
+	~int | ~int8 | ~int16 | ~int32 | ~int64 |
+		~uint | ~uint8 | ~uint16 | ~uint32 | ~uint64 | ~uintptr |
+		~float32 | ~float64
+}
+
+// Increment adds delta to the value of key k in a cache of numeric
+// values and returns the new value. If k is not in the cache, its
+// current value is taken to be zero.
+//
+// Increment is equivalent to getting the value, adding delta to it and
+// adding the result back to the cache, so the key is promoted to most
+// recently used and the Handler, if any, receives an Added event.
+//
+// Like Add, Increment respects MaxValueSize: if the new value is too
+// large, the cache is left unchanged and the key's current value, or
+// zero if it is not in the cache, is returned.
+func Increment[Key comparable, Value Number](c *Cache[Key, Value], k Key, delta Value) Value {
+	k = c.normalize(k)
+	var v Value
+	if ele, ok := c.cache[k]; ok {
+		v = ele.Value.(*entry[Key, Value]).value
+	}
+	if c.tooLarge(v + delta) {
+		return v
+	}
+	v += delta
+	_ = c.settle(c.add(k, v, nil, false))
+	return v
+}
@@ -0,0 +1,62 @@
+// Copyright 2022 The policy-lru Authors. All rights reserved.
+//
+// Use of this source code is governed by the Apache License, Version
+// 2.0 (the "License"); you may not use this file except in compliance
+// with the License. You may find a copy of the license in the file
+// LICENSE or at  http://www.apache.org/licenses/LICENSE-2.0.
+
+package policylru
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestIncrement(t *testing.T) {
+	t.Run("int64", func(t *testing.T) {
+		var updates []bool
+		lru := NewWithHandler[string, int64](MaxCount[string, int64](2), AddedFunc[string, int64](func(_ string, _, _ int64, update bool) {
+			updates = append(updates, update)
+		}))
+
+		v1 := Increment(lru, "a", 5)
+		v2 := Increment(lru, "a", -2)
+		Increment(lru, "b", 1)
+		Increment(lru, "a", 0)
+		Increment(lru, "c", 1)
+		value, ok := lru.Get("a")
+
+		assert.Equal(t, int64(5), v1)
+		assert.Equal(t, int64(3), v2)
+		assert.True(t, ok)
+		assert.Equal(t, int64(3), value)
+		assert.Equal(t, []bool{false, true, false, true, false}, updates)
+	})
+
+	t.Run("float64_zero_value", func(t *testing.T) {
+		var lru Cache[int, float64]
+
+		v := Increment(&lru, 1, 0.5)
+
+		assert.Equal(t, 0.5, v)
+		assert.Equal(t, 1, lru.Len())
+	})
+
+	t.Run("max_value_size", func(t *testing.T) {
+		lru := New[string, int](nil)
+		lru.MaxValueSize = 10
+		lru.ValueSize = func(v int) int64 { return int64(v) }
+
+		v1 := Increment(lru, "a", 8)
+		v2 := Increment(lru, "a", 5)
+		v3 := Increment(lru, "b", 11)
+		value, _ := lru.Get("a")
+
+		assert.Equal(t, 8, v1)
+		assert.Equal(t, 8, v2)
+		assert.Equal(t, 0, v3)
+		assert.Equal(t, 8, value)
+		assert.Equal(t, []string{"a"}, lru.orderedKeysForTest())
+	})
+}
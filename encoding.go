@@ -0,0 +1,113 @@
+// Copyright 2022 The policy-lru Authors. All rights reserved.
+//
+// Use of this source code is governed by the Apache License, Version
+// 2.0 (the "License"); you may not use this file except in compliance
+// with the License. You may find a copy of the license in the file
+// LICENSE or at  http://www.apache.org/licenses/LICENSE-2.0.
+
+package policylru
+
+import (
+	"bytes"
+	"encoding/gob"
+	"encoding/json"
+	"fmt"
+)
+
+// wireEntry is the serialized form of one item in the cache.
+type wireEntry[Key, Value any] struct {
+	K Key   `json:"key"`
+	V Value `json:"value"`
+}
+
+// wireEntries returns the items in the cache, least recently used
+// first, in their serialized form.
+func (c *Cache[Key, Value]) wireEntries() []wireEntry[Key, Value] {
+	entries := make([]wireEntry[Key, Value], 0, c.Len())
+	if c.cache == nil {
+		return entries
+	}
+	for ele := c.ll.Back(); ele != nil; ele = ele.Prev() {
+		e := ele.Value.(*entry[Key, Value])
+		entries = append(entries, wireEntry[Key, Value]{e.key, e.value})
+	}
+	return entries
+}
+
+// replaceWire replaces the contents of the cache with entries in their
+// serialized form, least recently used first.
+func (c *Cache[Key, Value]) replaceWire(entries []wireEntry[Key, Value]) {
+	kvs := make([]struct {
+		K Key
+		V Value
+	}, len(entries))
+	for i := range entries {
+		kvs[i].K, kvs[i].V = entries[i].K, entries[i].V
+	}
+	c.ReplaceAll(kvs)
+}
+
+// GobEncode implements gob.GobEncoder, encoding the keys and values of
+// the items in the cache in recency order, so that GobDecode restores
+// the same order. Only the items are encoded: the Policy, Handler and
+// other fields are not, and must be set again on the decoded cache.
+//
+// The keys and values must be encodable by the encoding/gob package. If
+// one is not, for example because it is an interface value whose
+// concrete type has not been registered with gob.Register, GobEncode
+// returns an error.
+func (c *Cache[Key, Value]) GobEncode() ([]byte, error) {
+	var buf bytes.Buffer
+	if err := gob.NewEncoder(&buf).Encode(c.wireEntries()); err != nil {
+		return nil, fmt.Errorf("policylru: gob encode: %w", err)
+	}
+	return buf.Bytes(), nil
+}
+
+// GobDecode implements gob.GobDecoder, replacing the contents of the
+// cache with the items encoded by GobEncode, as if by ReplaceAll. The
+// decoded items keep the recency order they had when they were
+// encoded.
+//
+// Since the Policy and Handler are not encoded, a cache decoded into a
+// zero value has neither. Set them before decoding to have the decoded
+// items generate Added events and be subject to the eviction policy.
+func (c *Cache[Key, Value]) GobDecode(data []byte) error {
+	var entries []wireEntry[Key, Value]
+	if err := gob.NewDecoder(bytes.NewReader(data)).Decode(&entries); err != nil {
+		return fmt.Errorf("policylru: gob decode: %w", err)
+	}
+	c.replaceWire(entries)
+	return nil
+}
+
+// MarshalJSON implements json.Marshaler, encoding the items in the
+// cache as a JSON array of objects of the form
+//
+//	{"key": k, "value": v}
+//
+// in recency order, starting with the least recently used item. Using
+// an array rather than a JSON object allows keys of any type which can
+// be marshaled, including structs. Only the items are encoded: the
+// Policy, Handler and other fields are not.
+func (c *Cache[Key, Value]) MarshalJSON() ([]byte, error) {
+	return json.Marshal(c.wireEntries())
+}
+
+// UnmarshalJSON implements json.Unmarshaler, replacing the contents of
+// the cache with the items encoded by MarshalJSON, as if by ReplaceAll,
+// so that they keep the recency order they had when they were encoded.
+// As with GobDecode, set the Policy and Handler before unmarshaling to
+// have them apply to the decoded items. A JSON null leaves the cache
+// unchanged.
+func (c *Cache[Key, Value]) UnmarshalJSON(data []byte) error {
+	if string(data) == "null" {
+		return nil
+	}
+	var entries []wireEntry[Key, Value]
+	if err := json.Unmarshal(data, &entries); err != nil {
+		return fmt.Errorf("policylru: %w", err)
+	}
+	c.replaceWire(entries)
+	return nil
+}
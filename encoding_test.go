@@ -0,0 +1,118 @@
+// Copyright 2022 The policy-lru Authors. All rights reserved.
+//
+// Use of this source code is governed by the Apache License, Version
+// 2.0 (the "License"); you may not use this file except in compliance
+// with the License. You may find a copy of the license in the file
+// LICENSE or at  http://www.apache.org/licenses/LICENSE-2.0.
+
+package policylru
+
+import (
+	"bytes"
+	"encoding/gob"
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGob(t *testing.T) {
+	t.Run("round_trip", func(t *testing.T) {
+		lru := New[string, []int](nil)
+		lru.Add("a", []int{1})
+		lru.Add("b", []int{2, 2})
+		lru.Add("c", nil)
+		lru.Get("a")
+		var buf bytes.Buffer
+
+		err := gob.NewEncoder(&buf).Encode(lru)
+		decoded := New[string, []int](MaxCount[string, []int](2))
+		err2 := gob.NewDecoder(&buf).Decode(decoded)
+
+		assert.NoError(t, err)
+		assert.NoError(t, err2)
+		assert.Equal(t, []string{"a", "c"}, decoded.orderedKeysForTest())
+		value, _ := decoded.Get("a")
+		assert.Equal(t, []int{1}, value)
+	})
+	t.Run("zero_value", func(t *testing.T) {
+		var lru Cache[string, int]
+
+		data, err := lru.GobEncode()
+		var decoded Cache[string, int]
+		err2 := decoded.GobDecode(data)
+
+		assert.NoError(t, err)
+		assert.NoError(t, err2)
+		assert.Equal(t, 0, decoded.Len())
+	})
+	t.Run("not_encodable", func(t *testing.T) {
+		type unregistered struct{ X int }
+		lru := New[string, any](nil)
+		lru.Add("a", unregistered{1})
+
+		_, err := lru.GobEncode()
+
+		assert.ErrorContains(t, err, "policylru: gob encode")
+	})
+	t.Run("bad_data", func(t *testing.T) {
+		var lru Cache[string, int]
+
+		err := lru.GobDecode([]byte("garbage"))
+
+		assert.ErrorContains(t, err, "policylru: gob decode")
+	})
+}
+
+func TestJSON(t *testing.T) {
+	type point struct {
+		X, Y int
+	}
+
+	t.Run("round_trip", func(t *testing.T) {
+		lru := New[point, string](nil)
+		lru.Add(point{1, 2}, "a")
+		lru.Add(point{3, 4}, "b")
+		lru.Add(point{5, 6}, "c")
+		lru.Get(point{1, 2})
+
+		data, err := json.Marshal(lru)
+		decoded := New[point, string](nil)
+		err2 := json.Unmarshal(data, decoded)
+
+		assert.NoError(t, err)
+		assert.JSONEq(t, `[
+			{"key": {"X": 3, "Y": 4}, "value": "b"},
+			{"key": {"X": 5, "Y": 6}, "value": "c"},
+			{"key": {"X": 1, "Y": 2}, "value": "a"}
+		]`, string(data))
+		assert.NoError(t, err2)
+		assert.Equal(t, []point{{1, 2}, {5, 6}, {3, 4}}, decoded.orderedKeysForTest())
+	})
+	t.Run("zero_value", func(t *testing.T) {
+		var lru Cache[string, int]
+
+		data, err := json.Marshal(&lru)
+
+		assert.NoError(t, err)
+		assert.Equal(t, "[]", string(data))
+	})
+	t.Run("null", func(t *testing.T) {
+		lru := New[string, int](nil)
+		lru.Add("a", 1)
+
+		err := json.Unmarshal([]byte("null"), lru)
+
+		assert.NoError(t, err)
+		assert.Equal(t, []string{"a"}, lru.orderedKeysForTest())
+	})
+	t.Run("bad_data", func(t *testing.T) {
+		lru := New[string, int](nil)
+		lru.Add("a", 1)
+
+		err := json.Unmarshal([]byte(`[{"key": 1, "value": 1}]`), lru)
+
+		assert.ErrorContains(t, err, "policylru: ")
+		assert.Equal(t, []string{"a"}, lru.orderedKeysForTest())
+	})
+}
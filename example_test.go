@@ -52,7 +52,7 @@ func (p *myPolicy) Removed(k string, v myValue) {
 // cache.
 func ExampleCache_withMaxSizePolicy() {
 	policy := &myPolicy{}
-	lru := policylru.NewWithHandler[string, myValue](policy, policy)
+	lru := policylru.NewTracked[string, myValue](policy)
 	lru.Add("foo", myValue{10})
 	lru.Add("bar", myValue{90})
 	lru.Add("baz", myValue{1})
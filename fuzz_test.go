@@ -0,0 +1,134 @@
+// Copyright 2022 The policy-lru Authors. All rights reserved.
+//
+// Use of this source code is governed by the Apache License, Version
+// 2.0 (the "License"); you may not use this file except in compliance
+// with the License. You may find a copy of the license in the file
+// LICENSE or at  http://www.apache.org/licenses/LICENSE-2.0.
+
+package policylru
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+// oracle is a deliberately naive reimplementation of a Cache governed
+// by a maximum count policy, used as a reference by FuzzCache.
+type oracle struct {
+	max    int
+	keys   []byte // Most recently used first.
+	values map[byte]byte
+}
+
+func (o *oracle) moveToFront(k byte) {
+	for i := range o.keys {
+		if o.keys[i] == k {
+			copy(o.keys[1:i+1], o.keys[:i])
+			o.keys[0] = k
+			return
+		}
+	}
+}
+
+func (o *oracle) add(k, v byte) (removed []byte) {
+	if _, ok := o.values[k]; ok {
+		o.moveToFront(k)
+		o.values[k] = v
+		return
+	}
+	o.keys = append([]byte{k}, o.keys...)
+	o.values[k] = v
+	return o.evict()
+}
+
+func (o *oracle) get(k byte) (byte, bool) {
+	v, ok := o.values[k]
+	if ok {
+		o.moveToFront(k)
+	}
+	return v, ok
+}
+
+func (o *oracle) remove(k byte) (removed []byte) {
+	if v, ok := o.values[k]; ok {
+		for i := range o.keys {
+			if o.keys[i] == k {
+				o.keys = append(o.keys[:i], o.keys[i+1:]...)
+				break
+			}
+		}
+		delete(o.values, k)
+		removed = append(removed, k, v)
+	}
+	return
+}
+
+func (o *oracle) evict() (removed []byte) {
+	for len(o.keys) > o.max {
+		k := o.keys[len(o.keys)-1]
+		removed = append(removed, o.remove(k)...)
+	}
+	return
+}
+
+func (o *oracle) clear() (removed []byte) {
+	for i := len(o.keys) - 1; i >= 0; i-- {
+		k := o.keys[i]
+		removed = append(removed, k, o.values[k])
+	}
+	o.keys = nil
+	o.values = map[byte]byte{}
+	return
+}
+
+// FuzzCache applies a sequence of operations decoded from the fuzz
+// input to both a Cache and an oracle and checks that they agree.
+//
+// Each operation is encoded in three bytes: an opcode, a key and a
+// value. Keys are folded into a small range so that the operations
+// collide often.
+func FuzzCache(f *testing.F) {
+	f.Add([]byte{0, 1, 1, 0, 2, 2, 0, 3, 3, 1, 1, 0, 0, 4, 4})
+	f.Add([]byte{0, 1, 1, 0, 1, 2, 2, 1, 0, 3, 0, 0, 4, 0, 0})
+	f.Add([]byte{3, 5, 0, 0, 1, 1, 0, 2, 2, 0, 3, 3, 3, 1, 0, 1, 2, 0})
+	f.Fuzz(func(t *testing.T, ops []byte) {
+		o := &oracle{max: 3, values: map[byte]byte{}}
+		var removed []byte
+		policy := PolicyFunc[byte, byte](func(_, _ byte, n int) bool {
+			return n > o.max
+		})
+		lru := NewWithHandler[byte, byte](policy, RemovedFunc[byte, byte](func(k, v byte) {
+			removed = append(removed, k, v)
+		}))
+		for i := 0; i+2 < len(ops); i += 3 {
+			op, k, v := ops[i]%5, ops[i+1]%8, ops[i+2]
+			removed = nil
+			var expected []byte
+			switch op {
+			case 0:
+				lru.Add(k, v)
+				expected = o.add(k, v)
+			case 1:
+				actualV, actualOK := lru.Get(k)
+				expectedV, expectedOK := o.get(k)
+				require.Equal(t, expectedOK, actualOK, "op %d: Get(%d) hit", i/3, k)
+				require.Equal(t, expectedV, actualV, "op %d: Get(%d) value", i/3, k)
+			case 2:
+				actualOK := lru.Remove(k)
+				expected = o.remove(k)
+				require.Equal(t, len(expected) > 0, actualOK, "op %d: Remove(%d)", i/3, k)
+			case 3:
+				o.max = int(v % 6)
+				actualN := lru.Evict()
+				expected = o.evict()
+				require.Equal(t, len(expected)/2, actualN, "op %d: Evict count", i/3)
+			case 4:
+				lru.Clear()
+				expected = o.clear()
+			}
+			require.Equal(t, expected, removed, "op %d: removals", i/3)
+			require.Equal(t, len(o.keys), lru.Len(), "op %d: Len", i/3)
+		}
+	})
+}
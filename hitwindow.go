@@ -0,0 +1,60 @@
+// Copyright 2022 The policy-lru Authors. All rights reserved.
+//
+// Use of this source code is governed by the Apache License, Version
+// 2.0 (the "License"); you may not use this file except in compliance
+// with the License. You may find a copy of the license in the file
+// LICENSE or at  http://www.apache.org/licenses/LICENSE-2.0.
+
+package policylru
+
+// hitWindow is a ring buffer of the outcomes of the most recent
+// lookups.
+type hitWindow struct {
+	hits  []bool
+	next  int
+	n     int
+	count int
+}
+
+// EnableHitWindow starts recording whether each of the last size
+// lookups made by Get and its variants hit or missed, so that
+// RecentHitRatio can report the hit ratio over them. Any outcomes
+// already recorded are discarded. If size is zero or negative,
+// recording is turned off.
+//
+// Unlike a lifetime hit ratio, which changes ever more slowly as the
+// cache ages, the ratio over a sliding window of recent lookups
+// quickly reveals a change in the cache's effectiveness.
+func (c *Cache[Key, Value]) EnableHitWindow(size int) {
+	if size <= 0 {
+		c.window = nil
+		return
+	}
+	c.window = &hitWindow{hits: make([]bool, size)}
+}
+
+// RecentHitRatio returns the fraction of the lookups recorded in the
+// hit window which were hits. It returns zero if the hit window is not
+// enabled or no lookups have been recorded.
+func (c *Cache[Key, Value]) RecentHitRatio() float64 {
+	w := c.window
+	if w == nil || w.n == 0 {
+		return 0
+	}
+	return float64(w.count) / float64(w.n)
+}
+
+func (w *hitWindow) record(hit bool) {
+	if w.n == len(w.hits) {
+		if w.hits[w.next] {
+			w.count--
+		}
+	} else {
+		w.n++
+	}
+	w.hits[w.next] = hit
+	if hit {
+		w.count++
+	}
+	w.next = (w.next + 1) % len(w.hits)
+}
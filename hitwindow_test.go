@@ -0,0 +1,54 @@
+// Copyright 2022 The policy-lru Authors. All rights reserved.
+//
+// Use of this source code is governed by the Apache License, Version
+// 2.0 (the "License"); you may not use this file except in compliance
+// with the License. You may find a copy of the license in the file
+// LICENSE or at  http://www.apache.org/licenses/LICENSE-2.0.
+
+package policylru
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRecentHitRatio(t *testing.T) {
+	t.Run("disabled", func(t *testing.T) {
+		lru := New[string, int](nil)
+		lru.Add("a", 1)
+
+		lru.Get("a")
+
+		assert.Equal(t, 0.0, lru.RecentHitRatio())
+	})
+
+	t.Run("window", func(t *testing.T) {
+		lru := New[string, int](nil)
+		lru.Add("a", 1)
+		lru.EnableHitWindow(4)
+
+		assert.Equal(t, 0.0, lru.RecentHitRatio())
+
+		lru.Get("a")
+		lru.Get("x")
+
+		assert.Equal(t, 0.5, lru.RecentHitRatio())
+
+		lru.Get("x")
+		lru.Get("x")
+		lru.Get("x")
+
+		assert.Equal(t, 0.0, lru.RecentHitRatio())
+
+		lru.Get("a")
+		lru.GetWithStale("a")
+		lru.Get("a")
+
+		assert.Equal(t, 0.75, lru.RecentHitRatio())
+
+		lru.EnableHitWindow(0)
+
+		assert.Equal(t, 0.0, lru.RecentHitRatio())
+	})
+}
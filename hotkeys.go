@@ -0,0 +1,71 @@
+// Copyright 2022 The policy-lru Authors. All rights reserved.
+//
+// Use of this source code is governed by the Apache License, Version
+// 2.0 (the "License"); you may not use this file except in compliance
+// with the License. You may find a copy of the license in the file
+// LICENSE or at  http://www.apache.org/licenses/LICENSE-2.0.
+
+package policylru
+
+import "sort"
+
+// EnableHotKeys starts counting the lookups of each key made by Get and
+// its variants, whether they hit or miss, so that the most frequently
+// accessed keys can be found with HotKeys. Any counts already recorded
+// are discarded.
+//
+// Counting adds a map update to every lookup, and the counts are kept
+// for every key looked up, including keys which are not in the cache,
+// until ResetHotKeys or DisableHotKeys is called.
+func (c *Cache[Key, Value]) EnableHotKeys() {
+	c.hot = make(map[Key]uint64)
+}
+
+// DisableHotKeys stops counting lookups and discards the counts.
+func (c *Cache[Key, Value]) DisableHotKeys() {
+	c.hot = nil
+}
+
+// ResetHotKeys discards the lookup counts recorded so far without
+// turning counting off.
+func (c *Cache[Key, Value]) ResetHotKeys() {
+	for k := range c.hot {
+		delete(c.hot, k)
+	}
+}
+
+// HotKeys returns up to n of the most frequently looked up keys, with
+// their lookup counts, most frequent first. Keys with equal counts are
+// returned in an unspecified order. If counting is not enabled,
+// HotKeys returns nil.
+func (c *Cache[Key, Value]) HotKeys(n int) []struct {
+	Key   Key
+	Count uint64
+} {
+	if c.hot == nil || n <= 0 {
+		return nil
+	}
+	hot := make([]struct {
+		Key   Key
+		Count uint64
+	}, 0, len(c.hot))
+	for k, count := range c.hot {
+		hot = append(hot, struct {
+			Key   Key
+			Count uint64
+		}{k, count})
+	}
+	sort.Slice(hot, func(i, j int) bool {
+		return hot[i].Count > hot[j].Count
+	})
+	if len(hot) > n {
+		hot = hot[:n]
+	}
+	return hot
+}
+
+func (c *Cache[Key, Value]) countHot(k Key) {
+	if c.hot != nil {
+		c.hot[k]++
+	}
+}
@@ -0,0 +1,61 @@
+// Copyright 2022 The policy-lru Authors. All rights reserved.
+//
+// Use of this source code is governed by the Apache License, Version
+// 2.0 (the "License"); you may not use this file except in compliance
+// with the License. You may find a copy of the license in the file
+// LICENSE or at  http://www.apache.org/licenses/LICENSE-2.0.
+
+package policylru
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestHotKeys(t *testing.T) {
+	t.Run("disabled", func(t *testing.T) {
+		lru := New[string, int](nil)
+
+		lru.Get("a")
+
+		assert.Nil(t, lru.HotKeys(1))
+	})
+
+	t.Run("counts", func(t *testing.T) {
+		lru := New[string, int](nil)
+		lru.EnableHotKeys()
+		lru.Add("a", 1)
+
+		lru.Get("a")
+		lru.Get("b")
+		lru.Get("a")
+		lru.GetWithStale("c")
+		lru.Get("c")
+		lru.Get("a")
+
+		assert.Equal(t, []struct {
+			Key   string
+			Count uint64
+		}{{"a", 3}, {"c", 2}}, lru.HotKeys(2))
+		assert.Len(t, lru.HotKeys(10), 3)
+	})
+
+	t.Run("reset", func(t *testing.T) {
+		lru := New[string, int](nil)
+		lru.EnableHotKeys()
+
+		lru.Get("a")
+		lru.ResetHotKeys()
+		lru.Get("b")
+
+		assert.Equal(t, []struct {
+			Key   string
+			Count uint64
+		}{{"b", 1}}, lru.HotKeys(5))
+
+		lru.DisableHotKeys()
+
+		assert.Nil(t, lru.HotKeys(5))
+	})
+}
@@ -0,0 +1,59 @@
+// Copyright 2022 The policy-lru Authors. All rights reserved.
+//
+// Use of this source code is governed by the Apache License, Version
+// 2.0 (the "License"); you may not use this file except in compliance
+// with the License. You may find a copy of the license in the file
+// LICENSE or at  http://www.apache.org/licenses/LICENSE-2.0.
+
+package policylru
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+)
+
+// LoadText warms the cache from a line-delimited text stream. Each
+// non-empty line read from r is converted to a key and value by parse
+// and added to the cache, in the order the lines are read, so the last
+// line loaded becomes the most recently used item.
+//
+// The eviction policy is run once, after the last line is loaded,
+// rather than after each line. The Handler, if any, receives an Added
+// event for each line loaded.
+//
+// If Overflow is OverflowReject, lines whose keys are rejected are not
+// counted as loaded. If Overflow is OverflowError, the first rejected
+// line is reported as an error wrapping ErrCacheFull. Lines whose
+// values exceed MaxValueSize are skipped and not counted as loaded.
+//
+// LoadText stops at the first error returned by r or parse, and
+// returns it along with the number of lines loaded before the error.
+func (c *Cache[Key, Value]) LoadText(r io.Reader, parse func(line string) (Key, Value, error)) (int, error) {
+	defer c.Evict()
+	s := bufio.NewScanner(r)
+	var n, lineNum int
+	for s.Scan() {
+		lineNum++
+		line := s.Text()
+		if line == "" {
+			continue
+		}
+		k, v, err := parse(line)
+		if err != nil {
+			return n, fmt.Errorf("policylru: line %d: %w", lineNum, err)
+		}
+		if c.tooLarge(v) {
+			continue
+		}
+		k = c.normalize(k)
+		if _, err := c.add(k, v, nil, false); err != nil {
+			if c.Overflow == OverflowError {
+				return n, fmt.Errorf("policylru: line %d: %w", lineNum, ErrCacheFull)
+			}
+			continue
+		}
+		n++
+	}
+	return n, s.Err()
+}
@@ -0,0 +1,84 @@
+// Copyright 2022 The policy-lru Authors. All rights reserved.
+//
+// Use of this source code is governed by the Apache License, Version
+// 2.0 (the "License"); you may not use this file except in compliance
+// with the License. You may find a copy of the license in the file
+// LICENSE or at  http://www.apache.org/licenses/LICENSE-2.0.
+
+package policylru
+
+import (
+	"errors"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func parseLine(line string) (string, int, error) {
+	k, v, ok := strings.Cut(line, "=")
+	if !ok {
+		return "", 0, errors.New("missing '='")
+	}
+	n, err := strconv.Atoi(v)
+	return k, n, err
+}
+
+func TestLoadText(t *testing.T) {
+	t.Run("success", func(t *testing.T) {
+		var evictions int
+		policy := PolicyFunc[string, int](func(_ string, _ int, n int) bool {
+			evictions++
+			return n > 2
+		})
+		lru := New[string, int](policy)
+
+		n, err := lru.LoadText(strings.NewReader("a=1\nb=2\n\nc=3\n"), parseLine)
+		_, okA := lru.Get("a")
+		valueC, okC := lru.Get("c")
+
+		assert.NoError(t, err)
+		assert.Equal(t, 3, n)
+		assert.Equal(t, 2, lru.Len())
+		assert.Equal(t, 2, evictions)
+		assert.False(t, okA)
+		assert.True(t, okC)
+		assert.Equal(t, 3, valueC)
+	})
+
+	t.Run("parse_error", func(t *testing.T) {
+		lru := New[string, int](MaxCount[string, int](10))
+
+		n, err := lru.LoadText(strings.NewReader("a=1\nb\nc=3\n"), parseLine)
+
+		assert.EqualError(t, err, "policylru: line 2: missing '='")
+		assert.Equal(t, 1, n)
+		assert.Equal(t, 1, lru.Len())
+	})
+
+	t.Run("max_value_size", func(t *testing.T) {
+		lru := New[string, int](nil)
+		lru.MaxValueSize = 9
+		lru.ValueSize = func(v int) int64 { return int64(v) }
+
+		n, err := lru.LoadText(strings.NewReader("a=1\nb=10\nc=3\n"), parseLine)
+		_, okB := lru.Get("b")
+
+		assert.NoError(t, err)
+		assert.Equal(t, 2, n)
+		assert.False(t, okB)
+	})
+
+	t.Run("overflow_error", func(t *testing.T) {
+		lru := New[string, int](MaxCount[string, int](2))
+		lru.Overflow = OverflowError
+
+		n, err := lru.LoadText(strings.NewReader("a=1\nb=2\nc=3\n"), parseLine)
+
+		assert.ErrorIs(t, err, ErrCacheFull)
+		assert.EqualError(t, err, "policylru: line 3: policylru: cache is full")
+		assert.Equal(t, 2, n)
+		assert.Equal(t, 2, lru.Len())
+	})
+}
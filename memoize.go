@@ -0,0 +1,119 @@
+// Copyright 2022 The policy-lru Authors. All rights reserved.
+//
+// Use of this source code is governed by the Apache License, Version
+// 2.0 (the "License"); you may not use this file except in compliance
+// with the License. You may find a copy of the license in the file
+// LICENSE or at  http://www.apache.org/licenses/LICENSE-2.0.
+
+package policylru
+
+import "sync"
+
+// Memoize returns a caching version of the function fn, backed by a
+// SyncCache with the given eviction policy. The returned function calls
+// fn on a cache miss and adds the result to the cache, and returns the
+// cached result on a cache hit.
+//
+// The returned function is safe for concurrent use. Concurrent callers
+// which miss the cache with the same input share a single call to fn:
+// the first of them calls fn, and the others wait for its result
+// rather than calling fn themselves. If fn panics, the panic propagates
+// to the caller which made the call, and one of the waiting callers
+// calls fn again.
+//
+// Since fn is called while no lock is held, it may itself call the
+// returned function, for example to memoize a recursive computation,
+// but not with the same input, which would wait for itself forever.
+func Memoize[In comparable, Out any](policy Policy[In, Out], fn func(In) Out) func(In) Out {
+	m := newMemo[In, Out](policy, func(in In) (Out, error) {
+		return fn(in), nil
+	})
+	return func(in In) Out {
+		out, _ := m.get(in)
+		return out
+	}
+}
+
+// MemoizeErr returns a caching version of the function fn, like
+// Memoize, for a function which can fail. If fn returns an error, the
+// error is returned and nothing is cached, so the next call with the
+// same input calls fn again. Concurrent callers sharing a call to fn
+// which fails all receive its error.
+//
+// Like the function returned by Memoize, the returned function is safe
+// for concurrent use.
+func MemoizeErr[In comparable, Out any](policy Policy[In, Out], fn func(In) (Out, error)) func(In) (Out, error) {
+	return newMemo[In, Out](policy, fn).get
+}
+
+// memo is the cache behind a memoized function. It remembers the calls
+// to fn in progress, so that concurrent callers with the same input
+// can wait for the result of one call.
+type memo[In comparable, Out any] struct {
+	c     *SyncCache[In, Out]
+	fn    func(In) (Out, error)
+	mu    sync.Mutex
+	calls map[In]*memoCall[Out]
+}
+
+// memoCall is a call to a memoized function which other callers may be
+// waiting for.
+type memoCall[Out any] struct {
+	wg   sync.WaitGroup
+	out  Out
+	err  error
+	done bool
+}
+
+func newMemo[In comparable, Out any](policy Policy[In, Out], fn func(In) (Out, error)) *memo[In, Out] {
+	return &memo[In, Out]{
+		c:     NewSync[In, Out](policy),
+		fn:    fn,
+		calls: make(map[In]*memoCall[Out]),
+	}
+}
+
+func (m *memo[In, Out]) get(in In) (Out, error) {
+	for {
+		if out, hit := m.c.Get(in); hit {
+			return out, nil
+		}
+		m.mu.Lock()
+		// A call which finished after the lookup above has already added
+		// its result to the cache, so look again before starting another.
+		if out, hit := m.c.Peek(in); hit {
+			m.mu.Unlock()
+			return out, nil
+		}
+		if call, ok := m.calls[in]; ok {
+			m.mu.Unlock()
+			call.wg.Wait()
+			if call.done {
+				return call.out, call.err
+			}
+			continue
+		}
+		call := &memoCall[Out]{}
+		call.wg.Add(1)
+		m.calls[in] = call
+		m.mu.Unlock()
+		m.call(in, call)
+		return call.out, call.err
+	}
+}
+
+// call calls fn for the input in, caching its result if it succeeds,
+// and then releases the callers waiting for it.
+func (m *memo[In, Out]) call(in In, call *memoCall[Out]) {
+	defer func() {
+		m.mu.Lock()
+		delete(m.calls, in)
+		m.mu.Unlock()
+		call.wg.Done()
+	}()
+	call.out, call.err = m.fn(in)
+	if call.err == nil {
+		m.c.Add(in, call.out)
+	}
+	call.done = true
+}
@@ -0,0 +1,147 @@
+// Copyright 2022 The policy-lru Authors. All rights reserved.
+//
+// Use of this source code is governed by the Apache License, Version
+// 2.0 (the "License"); you may not use this file except in compliance
+// with the License. You may find a copy of the license in the file
+// LICENSE or at  http://www.apache.org/licenses/LICENSE-2.0.
+
+package policylru
+
+import (
+	"errors"
+	"sync"
+	"sync/atomic"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMemoize(t *testing.T) {
+	var calls []int
+	square := Memoize[int, int](MaxCount[int, int](2), func(x int) int {
+		calls = append(calls, x)
+		return x * x
+	})
+
+	r1 := square(2)
+	r2 := square(2)
+	square(3)
+	square(4)
+	r3 := square(2)
+
+	assert.Equal(t, 4, r1)
+	assert.Equal(t, 4, r2)
+	assert.Equal(t, 4, r3)
+	assert.Equal(t, []int{2, 3, 4, 2}, calls)
+}
+
+func TestMemoizeErr(t *testing.T) {
+	errOdd := errors.New("odd")
+	var calls int
+	half := MemoizeErr[int, int](nil, func(x int) (int, error) {
+		calls++
+		if x%2 != 0 {
+			return 0, errOdd
+		}
+		return x / 2, nil
+	})
+
+	r1, err1 := half(4)
+	r2, err2 := half(4)
+	_, err3 := half(3)
+	_, err4 := half(3)
+
+	assert.Equal(t, 2, r1)
+	assert.NoError(t, err1)
+	assert.Equal(t, 2, r2)
+	assert.NoError(t, err2)
+	assert.ErrorIs(t, err3, errOdd)
+	assert.ErrorIs(t, err4, errOdd)
+	assert.Equal(t, 3, calls)
+}
+
+func TestMemoizeConcurrent(t *testing.T) {
+	t.Run("shares_call", func(t *testing.T) {
+		var calls int32
+		started := make(chan struct{})
+		release := make(chan struct{})
+		square := Memoize[int, int](nil, func(x int) int {
+			if atomic.AddInt32(&calls, 1) == 1 {
+				close(started)
+			}
+			<-release
+			return x * x
+		})
+		results := make([]int, 8)
+		var wg sync.WaitGroup
+
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			results[0] = square(3)
+		}()
+		<-started
+		for i := 1; i < len(results); i++ {
+			wg.Add(1)
+			go func(i int) {
+				defer wg.Done()
+				results[i] = square(3)
+			}(i)
+		}
+		close(release)
+		wg.Wait()
+
+		assert.Equal(t, int32(1), atomic.LoadInt32(&calls))
+		assert.Equal(t, []int{9, 9, 9, 9, 9, 9, 9, 9}, results)
+	})
+
+	t.Run("shares_error", func(t *testing.T) {
+		errFail := errors.New("fail")
+		var calls int32
+		started := make(chan struct{})
+		release := make(chan struct{})
+		fail := MemoizeErr[int, int](nil, func(int) (int, error) {
+			if atomic.AddInt32(&calls, 1) == 1 {
+				close(started)
+			}
+			<-release
+			return 0, errFail
+		})
+		errs := make([]error, 4)
+		var wg sync.WaitGroup
+
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_, errs[0] = fail(1)
+		}()
+		<-started
+		for i := 1; i < len(errs); i++ {
+			wg.Add(1)
+			go func(i int) {
+				defer wg.Done()
+				_, errs[i] = fail(1)
+			}(i)
+		}
+		close(release)
+		wg.Wait()
+
+		for _, err := range errs {
+			assert.ErrorIs(t, err, errFail)
+		}
+	})
+
+	t.Run("panic_releases_waiters", func(t *testing.T) {
+		var calls int32
+		f := Memoize[int, int](nil, func(x int) int {
+			if atomic.AddInt32(&calls, 1) == 1 {
+				panic("first call fails")
+			}
+			return x
+		})
+
+		assert.Panics(t, func() { f(1) })
+		assert.Equal(t, 1, f(1))
+		assert.Equal(t, int32(2), atomic.LoadInt32(&calls))
+	})
+}
@@ -0,0 +1,93 @@
+// Copyright 2022 The policy-lru Authors. All rights reserved.
+//
+// Use of this source code is governed by the Apache License, Version
+// 2.0 (the "License"); you may not use this file except in compliance
+// with the License. You may find a copy of the license in the file
+// LICENSE or at  http://www.apache.org/licenses/LICENSE-2.0.
+
+package policylru
+
+// OpMethod identifies the kind of cache operation recorded in an Op.
+type OpMethod int
+
+const (
+	// OpAdd records a call to Add or one of its variants.
+	OpAdd OpMethod = iota
+	// OpGet records a call to Get or one of its variants.
+	OpGet
+	// OpRemove records a call to Remove.
+	OpRemove
+	// OpEvict records the removal of one item by the eviction policy.
+	OpEvict
+)
+
+// String returns the name of the method, such as "Add".
+func (m OpMethod) String() string {
+	switch m {
+	case OpAdd:
+		return "Add"
+	case OpGet:
+		return "Get"
+	case OpRemove:
+		return "Remove"
+	case OpEvict:
+		return "Evict"
+	default:
+		return "OpMethod(?)"
+	}
+}
+
+// Op is an operation recorded in a cache's operation log.
+type Op[Key any] struct {
+	// Method is the kind of operation.
+	Method OpMethod
+	// Key is the key the operation applied to, after normalization.
+	Key Key
+	// Hit reports whether the key was found in the cache. For OpAdd, it
+	// is true if an existing value was updated. For OpEvict, it is
+	// always true.
+	Hit bool
+}
+
+// EnableOpLog starts recording the last size operations performed on
+// the cache, discarding any operations already recorded. If size is
+// zero or negative, recording is turned off.
+//
+// The log is kept in a ring buffer allocated by EnableOpLog, so
+// recording an operation does not allocate. Use DumpOpLog to retrieve
+// the recorded operations, for example to reproduce the state of the
+// cache after a failure.
+func (c *Cache[Key, Value]) EnableOpLog(size int) {
+	c.opNext, c.opLen = 0, 0
+	if size <= 0 {
+		c.oplog = nil
+		return
+	}
+	c.oplog = make([]Op[Key], size)
+}
+
+// DumpOpLog returns a copy of the recorded operations, oldest first.
+// If the operation log is not enabled, DumpOpLog returns nil.
+func (c *Cache[Key, Value]) DumpOpLog() []Op[Key] {
+	if c.oplog == nil {
+		return nil
+	}
+	ops := make([]Op[Key], 0, c.opLen)
+	start := c.opNext - c.opLen
+	if start < 0 {
+		ops = append(ops, c.oplog[start+len(c.oplog):]...)
+		start = 0
+	}
+	return append(ops, c.oplog[start:c.opNext]...)
+}
+
+func (c *Cache[Key, Value]) logOp(m OpMethod, k Key, hit bool) {
+	if c.oplog == nil {
+		return
+	}
+	c.oplog[c.opNext] = Op[Key]{m, k, hit}
+	c.opNext = (c.opNext + 1) % len(c.oplog)
+	if c.opLen < len(c.oplog) {
+		c.opLen++
+	}
+}
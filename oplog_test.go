@@ -0,0 +1,72 @@
+// Copyright 2022 The policy-lru Authors. All rights reserved.
+//
+// Use of this source code is governed by the Apache License, Version
+// 2.0 (the "License"); you may not use this file except in compliance
+// with the License. You may find a copy of the license in the file
+// LICENSE or at  http://www.apache.org/licenses/LICENSE-2.0.
+
+package policylru
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestOpLog(t *testing.T) {
+	t.Run("disabled", func(t *testing.T) {
+		lru := New[string, int](nil)
+
+		lru.Add("a", 1)
+
+		assert.Nil(t, lru.DumpOpLog())
+	})
+
+	t.Run("records", func(t *testing.T) {
+		lru := New[string, int](MaxCount[string, int](1))
+		lru.EnableOpLog(10)
+
+		lru.Add("a", 1)
+		lru.Add("a", 2)
+		lru.Get("a")
+		lru.Add("b", 3)
+		lru.Get("a")
+		lru.Remove("b")
+
+		assert.Equal(t, []Op[string]{
+			{OpAdd, "a", false},
+			{OpAdd, "a", true},
+			{OpGet, "a", true},
+			{OpAdd, "b", false},
+			{OpEvict, "a", true},
+			{OpGet, "a", false},
+			{OpRemove, "b", true},
+		}, lru.DumpOpLog())
+	})
+
+	t.Run("ring", func(t *testing.T) {
+		lru := New[string, int](nil)
+		lru.EnableOpLog(2)
+
+		lru.Get("a")
+		lru.Get("b")
+		lru.Get("c")
+
+		assert.Equal(t, []Op[string]{
+			{OpGet, "b", false},
+			{OpGet, "c", false},
+		}, lru.DumpOpLog())
+
+		lru.EnableOpLog(0)
+
+		assert.Nil(t, lru.DumpOpLog())
+	})
+}
+
+func TestOpMethodString(t *testing.T) {
+	assert.Equal(t, "Add", OpAdd.String())
+	assert.Equal(t, "Get", OpGet.String())
+	assert.Equal(t, "Remove", OpRemove.String())
+	assert.Equal(t, "Evict", OpEvict.String())
+	assert.Equal(t, "OpMethod(?)", OpMethod(-1).String())
+}
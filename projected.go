@@ -0,0 +1,130 @@
+// Copyright 2022 The policy-lru Authors. All rights reserved.
+//
+// Use of this source code is governed by the Apache License, Version
+// 2.0 (the "License"); you may not use this file except in compliance
+// with the License. You may find a copy of the license in the file
+// LICENSE or at  http://www.apache.org/licenses/LICENSE-2.0.
+
+package policylru
+
+import "reflect"
+
+// ProjectedCache is a Cache whose keys are compared by a projection
+// onto a comparable form rather than by ==. It is not safe for
+// concurrent access.
+//
+// ProjectedCache stores each item under keyOf(k), so two keys which
+// project to the same normalized key are treated as the same item:
+// adding one updates the item added with the other. Unlike
+// KeyNormalizer, the Key type need not be comparable, and the cache
+// remembers the original key most recently added for each item, which
+// is the key passed to the Policy and Handler.
+//
+// When Add updates an item under a different original key, the
+// Handler sees the item with the old key removed and the item with the
+// new key added, rather than an update, so that a Handler which tracks
+// keys, such as one of the built-in policies installed by NewTracked,
+// does not keep the old key forever.
+type ProjectedCache[Key any, NormalizedKey comparable, Value any] struct {
+	keyOf func(Key) NormalizedKey
+	c     *Cache[NormalizedKey, projected[Key, Value]]
+}
+
+type projected[Key, Value any] struct {
+	key   Key
+	value Value
+}
+
+type projectedPolicy[Key any, NormalizedKey comparable, Value any] struct {
+	p Policy[Key, Value]
+}
+
+func (p projectedPolicy[Key, NormalizedKey, Value]) Evict(_ NormalizedKey, v projected[Key, Value], n int) bool {
+	return p.p.Evict(v.key, v.value, n)
+}
+
+type projectedHandler[Key any, NormalizedKey comparable, Value any] struct {
+	h Handler[Key, Value]
+}
+
+func (h projectedHandler[Key, NormalizedKey, Value]) Added(_ NormalizedKey, old, new projected[Key, Value], update bool) {
+	if update && !sameKey(old.key, new.key) {
+		h.h.Removed(old.key, old.value)
+		var zero Value
+		h.h.Added(new.key, zero, new.value, false)
+		return
+	}
+	h.h.Added(new.key, old.value, new.value, update)
+}
+
+// sameKey reports whether a and b are the same original key, using ==
+// if their type is comparable and reflect.DeepEqual otherwise.
+func sameKey[Key any](a, b Key) bool {
+	x, y := any(a), any(b)
+	if x == nil || y == nil {
+		return x == y
+	}
+	if t := reflect.TypeOf(x); t != reflect.TypeOf(y) || !t.Comparable() {
+		return reflect.DeepEqual(x, y)
+	}
+	return x == y
+}
+
+func (h projectedHandler[Key, NormalizedKey, Value]) Removed(_ NormalizedKey, v projected[Key, Value]) {
+	h.h.Removed(v.key, v.value)
+}
+
+// NewProjected creates a new ProjectedCache which identifies keys by
+// keyOf, with the given eviction policy and event handler. Either of
+// policy and handler may be nil, with the same meaning as for
+// NewWithHandler.
+func NewProjected[Key any, NormalizedKey comparable, Value any](keyOf func(Key) NormalizedKey, policy Policy[Key, Value], handler Handler[Key, Value]) *ProjectedCache[Key, NormalizedKey, Value] {
+	c := NewWithHandler[NormalizedKey, projected[Key, Value]](nil, nil)
+	if policy != nil {
+		c.Policy = projectedPolicy[Key, NormalizedKey, Value]{policy}
+	}
+	if handler != nil {
+		c.Handler = projectedHandler[Key, NormalizedKey, Value]{handler}
+	}
+	return &ProjectedCache[Key, NormalizedKey, Value]{keyOf: keyOf, c: c}
+}
+
+// Add adds a value to the cache under the normalized key keyOf(k). If
+// an item with the same normalized key is already in the cache, its
+// value is updated and k replaces its original key.
+func (c *ProjectedCache[Key, NormalizedKey, Value]) Add(k Key, v Value) {
+	c.c.Add(c.keyOf(k), projected[Key, Value]{k, v})
+}
+
+// Get looks up the value of the item whose normalized key is keyOf(k).
+func (c *ProjectedCache[Key, NormalizedKey, Value]) Get(k Key) (v Value, hit bool) {
+	p, hit := c.c.Get(c.keyOf(k))
+	return p.value, hit
+}
+
+// GetKey looks up the item whose normalized key is keyOf(k), like Get,
+// and also returns the original key under which it was added.
+func (c *ProjectedCache[Key, NormalizedKey, Value]) GetKey(k Key) (original Key, v Value, hit bool) {
+	p, hit := c.c.Get(c.keyOf(k))
+	return p.key, p.value, hit
+}
+
+// Remove removes the item whose normalized key is keyOf(k).
+func (c *ProjectedCache[Key, NormalizedKey, Value]) Remove(k Key) (removed bool) {
+	return c.c.Remove(c.keyOf(k))
+}
+
+// Evict runs the eviction policy, like Cache.Evict.
+func (c *ProjectedCache[Key, NormalizedKey, Value]) Evict() (n int) {
+	return c.c.Evict()
+}
+
+// Len returns the number of items in the cache.
+func (c *ProjectedCache[Key, NormalizedKey, Value]) Len() int {
+	return c.c.Len()
+}
+
+// Clear purges all stored items from the cache, like Cache.Clear.
+func (c *ProjectedCache[Key, NormalizedKey, Value]) Clear() {
+	c.c.Clear()
+}
@@ -0,0 +1,98 @@
+// Copyright 2022 The policy-lru Authors. All rights reserved.
+//
+// Use of this source code is governed by the Apache License, Version
+// 2.0 (the "License"); you may not use this file except in compliance
+// with the License. You may find a copy of the license in the file
+// LICENSE or at  http://www.apache.org/licenses/LICENSE-2.0.
+
+package policylru
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type projectedKeyForTest struct {
+	ID    int
+	Label string
+}
+
+func TestProjectedCache(t *testing.T) {
+	byID := func(k projectedKeyForTest) int { return k.ID }
+
+	t.Run("same_projection_same_item", func(t *testing.T) {
+		var added, removed []projectedKeyForTest
+		lru := NewProjected[projectedKeyForTest, int, string](byID, nil, &handlerFuncs[projectedKeyForTest, string]{
+			added: func(k projectedKeyForTest, _, _ string, _ bool) {
+				added = append(added, k)
+			},
+			removed: func(k projectedKeyForTest, _ string) {
+				removed = append(removed, k)
+			},
+		})
+
+		lru.Add(projectedKeyForTest{1, "a"}, "x")
+		lru.Add(projectedKeyForTest{1, "b"}, "y")
+		original, value, ok := lru.GetKey(projectedKeyForTest{1, "c"})
+		shouldBeTrue := lru.Remove(projectedKeyForTest{1, "d"})
+
+		assert.True(t, ok)
+		assert.Equal(t, "y", value)
+		assert.Equal(t, projectedKeyForTest{1, "b"}, original)
+		assert.True(t, shouldBeTrue)
+		assert.Equal(t, 0, lru.Len())
+		assert.Equal(t, []projectedKeyForTest{{1, "a"}, {1, "b"}}, added)
+		assert.Equal(t, []projectedKeyForTest{{1, "a"}, {1, "b"}}, removed)
+	})
+	t.Run("new_original_key_replaces_old", func(t *testing.T) {
+		var events []string
+		lru := NewProjected[projectedKeyForTest, int, string](byID, nil, &handlerFuncs[projectedKeyForTest, string]{
+			added: func(k projectedKeyForTest, _, v string, update bool) {
+				events = append(events, fmt.Sprintf("+%s=%s %t", k.Label, v, update))
+			},
+			removed: func(k projectedKeyForTest, v string) {
+				events = append(events, fmt.Sprintf("-%s=%s", k.Label, v))
+			},
+		})
+
+		lru.Add(projectedKeyForTest{1, "a"}, "x")
+		lru.Add(projectedKeyForTest{1, "a"}, "y")
+		lru.Add(projectedKeyForTest{1, "b"}, "z")
+
+		assert.Equal(t, []string{"+a=x false", "+a=y true", "-a=y", "+b=z false"}, events)
+	})
+	t.Run("tracked_policy_forgets_old_key", func(t *testing.T) {
+		p := ExpireAfter[projectedKeyForTest, string](time.Hour)
+		lru := NewProjected[projectedKeyForTest, int, string](byID, p, p)
+
+		lru.Add(projectedKeyForTest{1, "a"}, "x")
+		lru.Add(projectedKeyForTest{1, "b"}, "y")
+		lru.Remove(projectedKeyForTest{1, "c"})
+
+		assert.Empty(t, p.added)
+		assert.Equal(t, 0, p.order.Len())
+	})
+	t.Run("policy_sees_original_keys", func(t *testing.T) {
+		var evicted []projectedKeyForTest
+		lru := NewProjected[projectedKeyForTest, int, string](byID, PolicyFunc[projectedKeyForTest, string](func(k projectedKeyForTest, _ string, n int) bool {
+			if n > 1 {
+				evicted = append(evicted, k)
+				return true
+			}
+			return false
+		}), nil)
+
+		lru.Add(projectedKeyForTest{1, "a"}, "x")
+		lru.Add(projectedKeyForTest{2, "b"}, "y")
+		_, missOld := lru.Get(projectedKeyForTest{1, "a"})
+		valueNew, hitNew := lru.Get(projectedKeyForTest{2, "z"})
+
+		assert.False(t, missOld)
+		assert.True(t, hitNew)
+		assert.Equal(t, "y", valueNew)
+		assert.Equal(t, []projectedKeyForTest{{1, "a"}}, evicted)
+	})
+}
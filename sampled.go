@@ -0,0 +1,156 @@
+// Copyright 2022 The policy-lru Authors. All rights reserved.
+//
+// Use of this source code is governed by the Apache License, Version
+// 2.0 (the "License"); you may not use this file except in compliance
+// with the License. You may find a copy of the license in the file
+// LICENSE or at  http://www.apache.org/licenses/LICENSE-2.0.
+
+package policylru
+
+import (
+	"math/rand"
+)
+
+type sampledEntry[Key, Value any] struct {
+	key    Key
+	value  Value
+	access uint64
+}
+
+// SampledCache is a fixed-capacity cache with approximate LRU eviction,
+// in the style of Redis. It is not safe for concurrent access.
+//
+// Rather than keeping its items in strict recency order, SampledCache
+// stamps each item with a logical access time when it is added or
+// read. When a new key is added to a full cache, it picks Samples items
+// at random and evicts the one with the oldest access time.
+//
+// Compared to Cache, a SampledCache does no list manipulation on Get
+// and uses less memory per item, but it may evict an item which is not
+// the least recently used. The more samples are taken, the closer its
+// choices are to those of a strict LRU cache, and the more expensive
+// each eviction is. Redis uses 5 samples by default, which in practice
+// approximates LRU well for typical access patterns.
+type SampledCache[Key comparable, Value any] struct {
+	// Samples is the number of items sampled to choose each item to
+	// evict. If Samples is less than 1, one item is sampled.
+	Samples int
+	// Rand optionally supplies the random numbers used for sampling.
+	// It must return a number in the range [0, n). If Rand is nil, the
+	// math/rand package is used. Set Rand to a deterministic source to
+	// make eviction reproducible.
+	Rand func(n int) int
+
+	capacity int
+	clock    uint64
+	entries  []sampledEntry[Key, Value]
+	index    map[Key]int
+}
+
+// NewSampledCache creates a new SampledCache which holds at most
+// capacity items, sampling the given number of items to choose each
+// item to evict. If capacity is zero or negative, the cache holds
+// nothing.
+func NewSampledCache[Key comparable, Value any](capacity, samples int) *SampledCache[Key, Value] {
+	if capacity < 0 {
+		capacity = 0
+	}
+	return &SampledCache[Key, Value]{
+		Samples:  samples,
+		capacity: capacity,
+		entries:  make([]sampledEntry[Key, Value], 0, capacity),
+		index:    make(map[Key]int, capacity),
+	}
+}
+
+func (c *SampledCache[Key, Value]) tick() uint64 {
+	c.clock++
+	return c.clock
+}
+
+// Add adds a value to the cache, evicting an item chosen by sampling
+// if the cache is full.
+func (c *SampledCache[Key, Value]) Add(k Key, v Value) {
+	if i, ok := c.index[k]; ok {
+		c.entries[i].value = v
+		c.entries[i].access = c.tick()
+		return
+	}
+	if c.capacity == 0 {
+		return
+	}
+	if len(c.entries) >= c.capacity {
+		c.removeAt(c.sample())
+	}
+	c.index[k] = len(c.entries)
+	c.entries = append(c.entries, sampledEntry[Key, Value]{k, v, c.tick()})
+}
+
+// Get looks up a key's value from the cache.
+func (c *SampledCache[Key, Value]) Get(k Key) (v Value, hit bool) {
+	var i int
+	if i, hit = c.index[k]; hit {
+		v = c.entries[i].value
+		c.entries[i].access = c.tick()
+	}
+	return
+}
+
+// Remove removes the provided key from the cache.
+func (c *SampledCache[Key, Value]) Remove(k Key) (removed bool) {
+	if i, ok := c.index[k]; ok {
+		c.removeAt(i)
+		return true
+	}
+	return false
+}
+
+// Len returns the number of items in the cache.
+func (c *SampledCache[Key, Value]) Len() int {
+	return len(c.entries)
+}
+
+// Clear purges all stored items from the cache.
+func (c *SampledCache[Key, Value]) Clear() {
+	for i := range c.entries {
+		c.entries[i] = sampledEntry[Key, Value]{}
+	}
+	c.entries = c.entries[:0]
+	for k := range c.index {
+		delete(c.index, k)
+	}
+}
+
+// sample returns the index of the item with the oldest access time
+// among a random sample of the items in the cache.
+func (c *SampledCache[Key, Value]) sample() int {
+	intn := c.Rand
+	if intn == nil {
+		intn = rand.Intn
+	}
+	samples := c.Samples
+	if samples < 1 {
+		samples = 1
+	}
+	oldest := intn(len(c.entries))
+	for s := 1; s < samples; s++ {
+		i := intn(len(c.entries))
+		if c.entries[i].access < c.entries[oldest].access {
+			oldest = i
+		}
+	}
+	return oldest
+}
+
+// removeAt removes the item at index i by moving the last item into
+// its place.
+func (c *SampledCache[Key, Value]) removeAt(i int) {
+	delete(c.index, c.entries[i].key)
+	last := len(c.entries) - 1
+	if i != last {
+		c.entries[i] = c.entries[last]
+		c.index[c.entries[i].key] = i
+	}
+	c.entries[last] = sampledEntry[Key, Value]{}
+	c.entries = c.entries[:last]
+}
@@ -0,0 +1,84 @@
+// Copyright 2022 The policy-lru Authors. All rights reserved.
+//
+// Use of this source code is governed by the Apache License, Version
+// 2.0 (the "License"); you may not use this file except in compliance
+// with the License. You may find a copy of the license in the file
+// LICENSE or at  http://www.apache.org/licenses/LICENSE-2.0.
+
+package policylru
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+var _ Cacher[string, int] = &SampledCache[string, int]{}
+
+func TestSampledCache(t *testing.T) {
+	t.Run("full_sample_is_exact_lru", func(t *testing.T) {
+		lru := NewSampledCache[string, int](3, 3)
+		var next int
+		lru.Rand = func(n int) int {
+			next++
+			return next % n
+		}
+
+		lru.Add("a", 1)
+		lru.Add("b", 2)
+		lru.Add("c", 3)
+		lru.Get("a")
+		lru.Add("d", 4)
+		_, okB := lru.Get("b")
+		valueA, okA := lru.Get("a")
+
+		assert.Equal(t, 3, lru.Len())
+		assert.False(t, okB)
+		assert.True(t, okA)
+		assert.Equal(t, 1, valueA)
+	})
+
+	t.Run("single_sample", func(t *testing.T) {
+		lru := NewSampledCache[string, int](2, 0)
+		lru.Rand = func(int) int { return 1 }
+
+		lru.Add("a", 1)
+		lru.Add("b", 2)
+		lru.Add("c", 3)
+		_, okA := lru.Get("a")
+		_, okB := lru.Get("b")
+
+		assert.True(t, okA)
+		assert.False(t, okB)
+	})
+
+	t.Run("update_remove_clear", func(t *testing.T) {
+		lru := NewSampledCache[string, int](3, 5)
+
+		lru.Add("a", 1)
+		lru.Add("b", 2)
+		lru.Add("a", 10)
+		shouldBeTrue := lru.Remove("a")
+		shouldBeFalse := lru.Remove("a")
+		value, ok := lru.Get("b")
+
+		assert.True(t, shouldBeTrue)
+		assert.False(t, shouldBeFalse)
+		assert.True(t, ok)
+		assert.Equal(t, 2, value)
+		assert.Equal(t, 1, lru.Len())
+
+		lru.Clear()
+		lru.Add("c", 3)
+
+		assert.Equal(t, 1, lru.Len())
+	})
+
+	t.Run("zero_capacity", func(t *testing.T) {
+		lru := NewSampledCache[string, int](0, 5)
+
+		lru.Add("a", 1)
+
+		assert.Equal(t, 0, lru.Len())
+	})
+}
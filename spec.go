@@ -0,0 +1,130 @@
+// Copyright 2022 The policy-lru Authors. All rights reserved.
+//
+// Use of this source code is governed by the Apache License, Version
+// 2.0 (the "License"); you may not use this file except in compliance
+// with the License. You may find a copy of the license in the file
+// LICENSE or at  http://www.apache.org/licenses/LICENSE-2.0.
+
+package policylru
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+)
+
+var byteUnits = map[string]uint64{
+	"":    1,
+	"b":   1,
+	"kb":  1000,
+	"mb":  1000 * 1000,
+	"gb":  1000 * 1000 * 1000,
+	"tb":  1000 * 1000 * 1000 * 1000,
+	"kib": 1 << 10,
+	"mib": 1 << 20,
+	"gib": 1 << 30,
+	"tib": 1 << 40,
+}
+
+// PolicyFromSpec returns a built-in Policy described by a
+// human-readable spec string, such as one read from a configuration
+// file. The recognized specs are:
+//
+//   - "count=N" returns a Policy which behaves like MaxCount(N).
+//   - "bytes=N[unit]" returns a Policy that evicts the oldest key from
+//     the Cache while the total length of the values in the cache
+//     exceeds N bytes. The optional unit is one of B, KB, MB, GB, TB
+//     (powers of 1000) or KiB, MiB, GiB, TiB (powers of 1024), and is
+//     not case-sensitive. This spec is only valid if Value is []byte
+//     or string.
+//
+// Some policies, such as the one returned for a "bytes" spec, track the
+// cache using Handler events, so the result is a PolicyHandler for
+// every spec, which should always be installed as both the policy and
+// the handler of the Cache, most easily by using NewTracked:
+//
+//	p, err := policylru.PolicyFromSpec[string, []byte](spec)
+//	if err != nil {
+//		return err
+//	}
+//	lru := policylru.NewTracked[string, []byte](p)
+//
+// The policy for a "count" spec ignores the Handler events.
+//
+// An error is returned if the spec is not recognized.
+func PolicyFromSpec[Key comparable, Value any](spec string) (PolicyHandler[Key, Value], error) {
+	name, arg, ok := strings.Cut(strings.TrimSpace(spec), "=")
+	if !ok {
+		return nil, fmt.Errorf("policylru: unrecognized policy spec %q", spec)
+	}
+	name = strings.ToLower(strings.TrimSpace(name))
+	arg = strings.TrimSpace(arg)
+	switch name {
+	case "count":
+		n, err := strconv.Atoi(arg)
+		if err != nil || n < 0 {
+			return nil, fmt.Errorf("policylru: invalid count in policy spec %q", spec)
+		}
+		return countSpecPolicy[Key, Value]{maxCountPolicy[Key, Value](n)}, nil
+	case "bytes":
+		size := defaultSizer[Value]()
+		if size == nil {
+			var zero Value
+			return nil, fmt.Errorf("policylru: policy spec %q requires []byte or string values, not %T", spec, zero)
+		}
+		n, err := parseBytes(arg)
+		if err != nil {
+			return nil, fmt.Errorf("policylru: invalid byte size in policy spec %q: %w", spec, err)
+		}
+		return &maxSizePolicy[Key, Value]{max: n, size: size}, nil
+	default:
+		return nil, fmt.Errorf("policylru: unrecognized policy spec %q", spec)
+	}
+}
+
+// countSpecPolicy is the policy for a "count" spec: a MaxCount policy
+// which ignores Handler events, so that it is a PolicyHandler like the
+// policies for the other specs.
+type countSpecPolicy[Key, Value any] struct {
+	maxCountPolicy[Key, Value]
+}
+
+func (countSpecPolicy[Key, Value]) Added(Key, Value, Value, bool) {}
+
+func (countSpecPolicy[Key, Value]) Removed(Key, Value) {}
+
+func parseBytes(s string) (uint64, error) {
+	i := strings.IndexFunc(s, func(r rune) bool { return r < '0' || r > '9' })
+	if i < 0 {
+		i = len(s)
+	}
+	unit, ok := byteUnits[strings.ToLower(strings.TrimSpace(s[i:]))]
+	if !ok {
+		return 0, fmt.Errorf("unknown unit %q", s[i:])
+	}
+	n, err := strconv.ParseUint(s[:i], 10, 64)
+	if err != nil {
+		return 0, err
+	}
+	if n > math.MaxUint64/unit {
+		return 0, fmt.Errorf("size %q overflows uint64", s)
+	}
+	return n * unit, nil
+}
+
+func defaultSizer[Value any]() func(Value) uint64 {
+	var zero Value
+	switch any(zero).(type) {
+	case []byte:
+		return func(v Value) uint64 {
+			return uint64(len(any(v).([]byte)))
+		}
+	case string:
+		return func(v Value) uint64 {
+			return uint64(len(any(v).(string)))
+		}
+	default:
+		return nil
+	}
+}
@@ -0,0 +1,84 @@
+// Copyright 2022 The policy-lru Authors. All rights reserved.
+//
+// Use of this source code is governed by the Apache License, Version
+// 2.0 (the "License"); you may not use this file except in compliance
+// with the License. You may find a copy of the license in the file
+// LICENSE or at  http://www.apache.org/licenses/LICENSE-2.0.
+
+package policylru
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPolicyFromSpec(t *testing.T) {
+	t.Run("count", func(t *testing.T) {
+		policy, err := PolicyFromSpec[string, int](" count = 2 ")
+		require.NoError(t, err)
+		lru := NewTracked[string, int](policy)
+
+		lru.Add("a", 1)
+		lru.Add("b", 2)
+		lru.Add("c", 3)
+
+		assert.Equal(t, []string{"c", "b"}, lru.orderedKeysForTest())
+	})
+
+	t.Run("count_zero", func(t *testing.T) {
+		policy, err := PolicyFromSpec[string, int]("count=0")
+		require.NoError(t, err)
+		lru := NewTracked[string, int](policy)
+
+		lru.Add("a", 1)
+
+		assert.Equal(t, 0, lru.Len())
+	})
+
+	t.Run("bytes", func(t *testing.T) {
+		policy, err := PolicyFromSpec[int, []byte]("bytes=1KiB")
+		require.NoError(t, err)
+		lru := NewTracked[int, []byte](policy)
+
+		lru.Add(1, make([]byte, 512))
+		lru.Add(2, make([]byte, 512))
+		assert.Equal(t, 2, lru.Len())
+		lru.Add(3, make([]byte, 1))
+		assert.Equal(t, 2, lru.Len())
+		_, ok := lru.Get(1)
+		assert.False(t, ok)
+	})
+
+	t.Run("byte_units", func(t *testing.T) {
+		testCases := map[string]uint64{
+			"bytes=10":     10,
+			"bytes=10B":    10,
+			"bytes=3kb":    3000,
+			"bytes=512MiB": 512 << 20,
+			"bytes=2 GB":   2000000000,
+			"bytes=1TiB":   1 << 40,
+		}
+		for spec, expected := range testCases {
+			policy, err := PolicyFromSpec[string, string](spec)
+			require.NoError(t, err, spec)
+			assert.Equal(t, expected, policy.(*maxSizePolicy[string, string]).max, spec)
+		}
+	})
+
+	t.Run("errors", func(t *testing.T) {
+		specs := []string{"", "count", "count=-1", "count=ten", "size=10", "bytes=10XB", "bytes=MiB", "bytes=99999999999TiB"}
+		for _, spec := range specs {
+			policy, err := PolicyFromSpec[string, string](spec)
+			assert.Error(t, err, spec)
+			assert.Nil(t, policy, spec)
+		}
+	})
+
+	t.Run("bytes_unsupported_value", func(t *testing.T) {
+		_, err := PolicyFromSpec[string, int]("bytes=10")
+
+		assert.EqualError(t, err, `policylru: policy spec "bytes=10" requires []byte or string values, not int`)
+	})
+}
@@ -0,0 +1,37 @@
+// Copyright 2022 The policy-lru Authors. All rights reserved.
+//
+// Use of this source code is governed by the Apache License, Version
+// 2.0 (the "License"); you may not use this file except in compliance
+// with the License. You may find a copy of the license in the file
+// LICENSE or at  http://www.apache.org/licenses/LICENSE-2.0.
+
+package policylru
+
+// Stats holds counts of the operations on a Cache since it was created
+// or its statistics were last reset.
+type Stats struct {
+	// Hits is the number of lookups, by Get or one of its variants,
+	// which found the key in the cache.
+	Hits uint64
+	// Misses is the number of lookups which did not find the key in
+	// the cache, including those then served by the cache's Fallback.
+	Misses uint64
+	// Evictions is the number of items removed by the eviction policy.
+	// Items removed by Remove or Clear are not counted.
+	Evictions uint64
+	// Adds is the number of values stored by Add or one of its
+	// variants, whether they added a new item or updated an existing
+	// one.
+	Adds uint64
+}
+
+// Stats returns a snapshot of the cache's statistics. The counts are
+// not reset by Clear.
+func (c *Cache[Key, Value]) Stats() Stats {
+	return c.stats
+}
+
+// ResetStats sets all the cache's statistics back to zero.
+func (c *Cache[Key, Value]) ResetStats() {
+	c.stats = Stats{}
+}
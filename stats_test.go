@@ -0,0 +1,40 @@
+// Copyright 2022 The policy-lru Authors. All rights reserved.
+//
+// Use of this source code is governed by the Apache License, Version
+// 2.0 (the "License"); you may not use this file except in compliance
+// with the License. You may find a copy of the license in the file
+// LICENSE or at  http://www.apache.org/licenses/LICENSE-2.0.
+
+package policylru
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestStats(t *testing.T) {
+	t.Run("zero_value", func(t *testing.T) {
+		var lru Cache[string, int]
+
+		assert.Equal(t, Stats{}, lru.Stats())
+	})
+	t.Run("counts", func(t *testing.T) {
+		lru := New[string, int](MaxCount[string, int](2))
+
+		lru.Add("a", 1)
+		lru.Add("b", 2)
+		lru.Add("a", 10)
+		lru.Get("a")
+		lru.Get("x")
+		lru.Peek("a")
+		lru.Add("c", 3)
+		lru.Remove("a")
+		lru.Clear()
+		stats := lru.Stats()
+		lru.ResetStats()
+
+		assert.Equal(t, Stats{Hits: 1, Misses: 1, Evictions: 1, Adds: 4}, stats)
+		assert.Equal(t, Stats{}, lru.Stats())
+	})
+}
@@ -0,0 +1,176 @@
+// Copyright 2022 The policy-lru Authors. All rights reserved.
+//
+// Use of this source code is governed by the Apache License, Version
+// 2.0 (the "License"); you may not use this file except in compliance
+// with the License. You may find a copy of the license in the file
+// LICENSE or at  http://www.apache.org/licenses/LICENSE-2.0.
+
+package policylru
+
+import "sync"
+
+// SyncCache is a Cache which is safe for concurrent access by multiple
+// goroutines. Each method locks the cache for the duration of the
+// corresponding Cache method.
+//
+// The eviction policy and the Handler are called while the lock is
+// held, so they must not call back into the SyncCache, or they will
+// deadlock.
+//
+// The zero value of SyncCache is an empty cache with no eviction
+// policy and no Handler, ready to use.
+type SyncCache[Key comparable, Value any] struct {
+	mu sync.RWMutex
+	c  Cache[Key, Value]
+}
+
+// NewSync creates a new SyncCache with the given eviction policy. It
+// is the concurrent counterpart of New.
+func NewSync[Key comparable, Value any](policy Policy[Key, Value]) *SyncCache[Key, Value] {
+	return NewSyncWithHandler(policy, nil)
+}
+
+// NewSyncWithHandler creates a new SyncCache with the given eviction
+// policy and event handler. It is the concurrent counterpart of
+// NewWithHandler.
+func NewSyncWithHandler[Key comparable, Value any](policy Policy[Key, Value], handler Handler[Key, Value]) *SyncCache[Key, Value] {
+	s := &SyncCache[Key, Value]{}
+	s.c.Policy = policy
+	s.c.Handler = handler
+	return s
+}
+
+// SetPolicy replaces the cache eviction policy, like Cache.SetPolicy.
+func (c *SyncCache[Key, Value]) SetPolicy(p Policy[Key, Value]) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.c.SetPolicy(p)
+}
+
+// SetHandler replaces the cache event handler, like Cache.SetHandler.
+func (c *SyncCache[Key, Value]) SetHandler(h Handler[Key, Value]) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.c.SetHandler(h)
+}
+
+// Add adds a value to the cache, like Cache.Add.
+func (c *SyncCache[Key, Value]) Add(k Key, v Value) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.c.Add(k, v)
+}
+
+// Get looks up a key's value from the cache, like Cache.Get. Because
+// Get promotes the item it finds, it takes the write lock.
+func (c *SyncCache[Key, Value]) Get(k Key) (v Value, hit bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.c.Get(k)
+}
+
+// Peek looks up a key's value from the cache without promoting it, like
+// Cache.Peek. Peek only takes the read lock, so it can run concurrently
+// with other calls to Peek and Len.
+func (c *SyncCache[Key, Value]) Peek(k Key) (v Value, hit bool) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.c.Peek(k)
+}
+
+// PeekAll looks up the values of several keys from the cache without
+// promoting them, like Cache.PeekAll. The read lock is taken once for
+// the whole batch, so the values found are a consistent snapshot.
+func (c *SyncCache[Key, Value]) PeekAll(keys []Key) (values map[Key]Value, misses []Key) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.c.PeekAll(keys)
+}
+
+// Remove removes the provided key from the cache, like Cache.Remove.
+func (c *SyncCache[Key, Value]) Remove(k Key) (removed bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.c.Remove(k)
+}
+
+// Evict runs the eviction policy, like Cache.Evict.
+func (c *SyncCache[Key, Value]) Evict() (n int) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.c.Evict()
+}
+
+// Len returns the number of items in the cache.
+func (c *SyncCache[Key, Value]) Len() int {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.c.Len()
+}
+
+// Clear purges all stored items from the cache, like Cache.Clear.
+func (c *SyncCache[Key, Value]) Clear() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.c.Clear()
+}
+
+// ReplaceAll replaces the entire contents of the cache with entries,
+// like Cache.ReplaceAll. Since the lock is held throughout, other
+// goroutines see either the old contents or the new ones, and never a
+// partially refilled cache.
+func (c *SyncCache[Key, Value]) ReplaceAll(entries []struct {
+	K Key
+	V Value
+}) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.c.ReplaceAll(entries)
+}
+
+// DrainAll removes all the items from the cache and returns them, from
+// least to most recently used, under a single lock acquisition, so that
+// no item added by another goroutine is lost between reading the items
+// and clearing the cache. As with Clear, the Handler receives a Removed
+// event for each item.
+func (c *SyncCache[Key, Value]) DrainAll() []struct {
+	K Key
+	V Value
+} {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	items := make([]struct {
+		K Key
+		V Value
+	}, 0, c.c.Len())
+	c.c.Range(func(k Key, v Value) bool {
+		items = append(items, struct {
+			K Key
+			V Value
+		}{k, v})
+		return true
+	})
+	c.c.Clear()
+	return items
+}
+
+// Atomically calls f with the underlying Cache while holding the lock,
+// so that f can combine several operations on the cache into one atomic
+// operation. The function f must not call any method of the SyncCache,
+// which would deadlock, and must not keep the Cache after it returns,
+// since the Cache is not safe for concurrent use.
+func (c *SyncCache[Key, Value]) Atomically(f func(unlocked *Cache[Key, Value])) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	f(&c.c)
+}
+
+// IncrementSync adds delta to the value of key k in a SyncCache of
+// numeric values and returns the new value, like Increment, while
+// holding the lock, so that concurrent increments of the same key are
+// not lost.
+func IncrementSync[Key comparable, Value Number](c *SyncCache[Key, Value], k Key, delta Value) Value {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return Increment(&c.c, k, delta)
+}
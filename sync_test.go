@@ -0,0 +1,170 @@
+// Copyright 2022 The policy-lru Authors. All rights reserved.
+//
+// Use of this source code is governed by the Apache License, Version
+// 2.0 (the "License"); you may not use this file except in compliance
+// with the License. You may find a copy of the license in the file
+// LICENSE or at  http://www.apache.org/licenses/LICENSE-2.0.
+
+package policylru
+
+import (
+	"strconv"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSyncCache(t *testing.T) {
+	t.Run("basic", func(t *testing.T) {
+		var removed []string
+		lru := NewSyncWithHandler[string, int](MaxCount[string, int](2), RemovedFunc[string, int](func(k string, _ int) {
+			removed = append(removed, k)
+		}))
+
+		lru.Add("a", 1)
+		lru.Add("b", 2)
+		lru.Get("a")
+		lru.Add("c", 3)
+		_, okB := lru.Peek("b")
+		valueA, okA := lru.Get("a")
+		shouldBeTrue := lru.Remove("c")
+		lru.Clear()
+
+		assert.False(t, okB)
+		assert.True(t, okA)
+		assert.Equal(t, 1, valueA)
+		assert.True(t, shouldBeTrue)
+		assert.Equal(t, 0, lru.Len())
+		assert.Equal(t, []string{"b", "c", "a"}, removed)
+	})
+	t.Run("evict", func(t *testing.T) {
+		limit := 3
+		lru := NewSync[string, int](PolicyFunc[string, int](func(_ string, _ int, n int) bool {
+			return n > limit
+		}))
+		lru.Add("a", 1)
+		lru.Add("b", 2)
+		lru.Add("c", 3)
+		limit = 1
+
+		n := lru.Evict()
+
+		assert.Equal(t, 2, n)
+		assert.Equal(t, 1, lru.Len())
+	})
+	t.Run("zero_value", func(t *testing.T) {
+		var lru SyncCache[string, int]
+
+		_, ok := lru.Peek("a")
+		lru.Add("a", 1)
+		value, hit := lru.Get("a")
+
+		assert.False(t, ok)
+		assert.True(t, hit)
+		assert.Equal(t, 1, value)
+		assert.Equal(t, 1, lru.Len())
+	})
+	t.Run("set_policy_and_handler", func(t *testing.T) {
+		var removed []string
+		var lru SyncCache[string, int]
+		lru.Add("a", 1)
+		lru.Add("b", 2)
+
+		lru.SetHandler(RemovedFunc[string, int](func(k string, _ int) {
+			removed = append(removed, k)
+		}))
+		lru.SetPolicy(MaxCount[string, int](1))
+		n := lru.Evict()
+
+		assert.Equal(t, 1, n)
+		assert.Equal(t, []string{"a"}, removed)
+	})
+	t.Run("replace_all_and_peek_all", func(t *testing.T) {
+		lru := NewSync[string, int](nil)
+		lru.Add("x", 0)
+
+		lru.ReplaceAll([]struct {
+			K string
+			V int
+		}{{"a", 1}, {"b", 2}})
+		values, misses := lru.PeekAll([]string{"a", "x", "b"})
+
+		assert.Equal(t, map[string]int{"a": 1, "b": 2}, values)
+		assert.Equal(t, []string{"x"}, misses)
+	})
+	t.Run("drain_all", func(t *testing.T) {
+		var removed []string
+		lru := NewSyncWithHandler[string, int](nil, RemovedFunc[string, int](func(k string, _ int) {
+			removed = append(removed, k)
+		}))
+		lru.Add("a", 1)
+		lru.Add("b", 2)
+		lru.Get("a")
+
+		items := lru.DrainAll()
+
+		assert.Equal(t, []struct {
+			K string
+			V int
+		}{{"b", 2}, {"a", 1}}, items)
+		assert.Equal(t, []string{"b", "a"}, removed)
+		assert.Equal(t, 0, lru.Len())
+		assert.Empty(t, lru.DrainAll())
+	})
+	t.Run("atomically", func(t *testing.T) {
+		lru := NewSync[string, int](nil)
+		lru.Add("a", 1)
+
+		lru.Atomically(func(unlocked *Cache[string, int]) {
+			v, _ := unlocked.Get("a")
+			unlocked.Add("b", v+1)
+		})
+		value, ok := lru.Peek("b")
+
+		assert.True(t, ok)
+		assert.Equal(t, 2, value)
+	})
+	t.Run("increment", func(t *testing.T) {
+		var lru SyncCache[string, int]
+		var wg sync.WaitGroup
+
+		for i := 0; i < 8; i++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				for j := 0; j < 100; j++ {
+					IncrementSync(&lru, "n", 1)
+				}
+			}()
+		}
+		wg.Wait()
+		value, _ := lru.Peek("n")
+
+		assert.Equal(t, 800, value)
+	})
+	t.Run("concurrent", func(t *testing.T) {
+		lru := NewSync[string, int](MaxCount[string, int](10))
+		var wg sync.WaitGroup
+
+		for i := 0; i < 8; i++ {
+			wg.Add(1)
+			go func(i int) {
+				defer wg.Done()
+				for j := 0; j < 100; j++ {
+					k := strconv.Itoa((i + j) % 20)
+					lru.Add(k, j)
+					lru.Get(k)
+					lru.Peek(k)
+					lru.Len()
+					if j%10 == 0 {
+						lru.Remove(k)
+					}
+				}
+			}(i)
+		}
+		wg.Wait()
+
+		assert.LessOrEqual(t, lru.Len(), 10)
+	})
+}
@@ -0,0 +1,84 @@
+// Copyright 2022 The policy-lru Authors. All rights reserved.
+//
+// Use of this source code is governed by the Apache License, Version
+// 2.0 (the "License"); you may not use this file except in compliance
+// with the License. You may find a copy of the license in the file
+// LICENSE or at  http://www.apache.org/licenses/LICENSE-2.0.
+
+package policylru
+
+// WatchKey returns a channel which receives the new value of the key k
+// each time k is added to the cache or its value is updated, and which
+// is closed when k is removed from the cache, for whatever reason. The
+// returned function stops the watch and closes the channel, if it has
+// not already been closed. Once the channel is closed, the watch is
+// over, and a new watch must be started to observe the key again.
+//
+// Values are delivered without blocking the cache. The channel holds a
+// single value, so if the receiver has not yet received the previous
+// value when a new one is delivered, the previous value is replaced.
+// The receiver always sees the latest value, but may miss some of the
+// values in between.
+//
+// Like the rest of Cache, WatchKey and the returned function must not
+// be called concurrently with other methods, although the channel may
+// be received from on any goroutine.
+func (c *Cache[Key, Value]) WatchKey(k Key) (<-chan Value, func()) {
+	k = c.normalize(k)
+	ch := make(chan Value, 1)
+	if c.watchers == nil {
+		c.watchers = make(map[Key][]chan Value)
+	}
+	c.watchers[k] = append(c.watchers[k], ch)
+	return ch, func() {
+		chans := c.watchers[k]
+		for i := range chans {
+			if chans[i] == ch {
+				close(ch)
+				c.setWatchers(k, append(chans[:i:i], chans[i+1:]...))
+				return
+			}
+		}
+	}
+}
+
+func (c *Cache[Key, Value]) setWatchers(k Key, chans []chan Value) {
+	if len(chans) > 0 {
+		c.watchers[k] = chans
+		return
+	}
+	delete(c.watchers, k)
+	if len(c.watchers) == 0 {
+		c.watchers = nil
+	}
+}
+
+// notifyWatchers delivers the new value v of the key k to the watchers
+// of k, replacing any value they have not yet received.
+func (c *Cache[Key, Value]) notifyWatchers(k Key, v Value) {
+	if c.watchers == nil {
+		return
+	}
+	for _, ch := range c.watchers[k] {
+		select {
+		case ch <- c.copyOut(v):
+		default:
+			select {
+			case <-ch:
+			default:
+			}
+			ch <- c.copyOut(v)
+		}
+	}
+}
+
+// closeWatchers ends the watches of the removed key k.
+func (c *Cache[Key, Value]) closeWatchers(k Key) {
+	if c.watchers == nil {
+		return
+	}
+	for _, ch := range c.watchers[k] {
+		close(ch)
+	}
+	c.setWatchers(k, nil)
+}
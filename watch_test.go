@@ -0,0 +1,88 @@
+// Copyright 2022 The policy-lru Authors. All rights reserved.
+//
+// Use of this source code is governed by the Apache License, Version
+// 2.0 (the "License"); you may not use this file except in compliance
+// with the License. You may find a copy of the license in the file
+// LICENSE or at  http://www.apache.org/licenses/LICENSE-2.0.
+
+package policylru
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestWatchKey(t *testing.T) {
+	t.Run("values_and_removal", func(t *testing.T) {
+		lru := New[string, int](nil)
+		ch, _ := lru.WatchKey("a")
+
+		lru.Add("a", 1)
+		v1 := <-ch
+		lru.Add("b", 2)
+		lru.Add("a", 2)
+		lru.Add("a", 3)
+		v2 := <-ch
+		lru.Remove("a")
+		_, open := <-ch
+
+		assert.Equal(t, 1, v1)
+		assert.Equal(t, 3, v2)
+		assert.False(t, open)
+		assert.Nil(t, lru.watchers)
+	})
+
+	t.Run("eviction_and_clear", func(t *testing.T) {
+		lru := New[string, int](MaxCount[string, int](1))
+		chA, _ := lru.WatchKey("a")
+		chB, _ := lru.WatchKey("b")
+		lru.Add("a", 1)
+		lru.Add("b", 2)
+		<-chA
+		_, openA := <-chA
+		<-chB
+
+		lru.Clear()
+		_, openB := <-chB
+
+		assert.False(t, openA)
+		assert.False(t, openB)
+	})
+
+	t.Run("replace_all", func(t *testing.T) {
+		lru := New[string, int](nil)
+		lru.Add("a", 1)
+		chA, _ := lru.WatchKey("a")
+		chB, _ := lru.WatchKey("b")
+
+		lru.ReplaceAll([]struct {
+			K string
+			V int
+		}{{"b", 2}})
+		_, openA := <-chA
+		valueB := <-chB
+
+		assert.False(t, openA)
+		assert.Equal(t, 2, valueB)
+	})
+
+	t.Run("unsubscribe", func(t *testing.T) {
+		lru := New[string, int](nil)
+		ch1, stop1 := lru.WatchKey("a")
+		ch2, stop2 := lru.WatchKey("a")
+
+		stop1()
+		stop1()
+		lru.Add("a", 1)
+		_, open1 := <-ch1
+		value2 := <-ch2
+		stop2()
+		_, open2 := <-ch2
+
+		assert.False(t, open1)
+		assert.Equal(t, 1, value2)
+		assert.False(t, open2)
+		assert.Nil(t, lru.watchers)
+	})
+}